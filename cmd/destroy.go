@@ -3,10 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/frostyard/igloo/internal/config"
-	"github.com/frostyard/igloo/internal/incus"
-	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -22,7 +21,10 @@ By default, it also removes the .igloo configuration directory.`,
 		Example: `  # Destroy the igloo environment
   igloo destroy
 
-  # Force destroy without confirmation
+  # Destroy without asking for confirmation
+  igloo destroy --yes
+
+  # Force destroy without stopping first
   igloo destroy --force
 
   # Keep the .igloo directory
@@ -39,45 +41,64 @@ By default, it also removes the .igloo configuration directory.`,
 }
 
 func runDestroy(force, keepConfig bool) error {
-	styles := ui.NewStyles()
-
-	// Load config
-	cfg, err := config.Load(config.ConfigPath())
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	client := incus.NewClient()
+	client := newClient()
 
 	// Check if instance exists
-	exists, err := client.InstanceExists(cfg.Container.Name)
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
+	if err != nil {
+		return err
+	}
+	exists := inst != nil
+
+	question := fmt.Sprintf("Destroy container %s and delete .igloo/?", cfg.Container.Name)
+	if keepConfig {
+		question = fmt.Sprintf("Destroy container %s?", cfg.Container.Name)
+	}
+	confirmed, err := prompter.Confirm(question)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
+	}
+	if !confirmed {
+		report.Info("Aborted")
+		return nil
 	}
 
 	if exists {
-		fmt.Println(styles.Info(fmt.Sprintf("Destroying container %s...", cfg.Container.Name)))
+		report.Info(fmt.Sprintf("Destroying container %s...", cfg.Container.Name))
 		if err := client.Delete(cfg.Container.Name, force); err != nil {
 			return fmt.Errorf("failed to destroy instance: %w", err)
 		}
-		fmt.Println(styles.Success(fmt.Sprintf("Container %s destroyed", cfg.Container.Name)))
+		report.Success(fmt.Sprintf("Container %s destroyed", cfg.Container.Name))
 	} else {
-		fmt.Println(styles.Warning(fmt.Sprintf("Container %s does not exist", cfg.Container.Name)))
+		report.Warning(fmt.Sprintf("Container %s does not exist", cfg.Container.Name))
 	}
 
 	// Remove stored config hash
 	if err := config.RemoveStoredHash(cfg.Container.Name); err != nil {
-		fmt.Println(styles.Warning(fmt.Sprintf("Could not remove stored hash: %v", err)))
+		report.Warning(fmt.Sprintf("Could not remove stored hash: %v", err))
+	}
+
+	// Launchers and shims exported from the container would no longer work
+	removeExports(cfg.Container.Name)
+
+	if err := config.RemoveRegistryEntry(cfg.Container.Name); err != nil {
+		report.Warning(fmt.Sprintf("Could not remove registry entry: %v", err))
 	}
 
 	// Remove .igloo directory unless --keep-config
 	if !keepConfig {
-		fmt.Println(styles.Info("Removing .igloo directory..."))
-		if err := os.RemoveAll(config.ConfigDir); err != nil {
+		report.Info("Removing .igloo directory...")
+		if err := os.RemoveAll(filepath.Join(projectDir, config.ConfigDir)); err != nil {
 			return fmt.Errorf("failed to remove .igloo directory: %w", err)
 		}
 	}
 
-	fmt.Println(styles.Success("Igloo environment destroyed"))
+	report.Success("Igloo environment destroyed")
 	return nil
 }
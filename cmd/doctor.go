@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/doctor"
+	"github.com/frostyard/igloo/internal/forward"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that this machine is ready to run igloo",
+		Long: `Doctor checks every prerequisite igloo needs from the host: incus is
+installed and initialized with a storage pool and network, you can talk to the
+daemon, the kernel supports the idmapped mounts used for your home and project,
+subordinate IDs are delegated to incus, and the display server can be reached.
+Run inside a project that forwards gpg-agent, it also signs a test message in
+the container to check commit signing works end to end.
+
+Each failed check explains what is wrong and how to fix it. Doctor exits with
+a non-zero status if any check failed.`,
+		Example: `  # Check the host
+  igloo doctor
+
+  # Check the host and output the results as JSON
+  igloo doctor -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor()
+		},
+	}
+
+	return cmd
+}
+
+func runDoctor() error {
+	client := newClient()
+	host := doctor.DetectHost(client)
+
+	// Inside a project that forwards gpg-agent, check signing end to end
+	if projectDir, cfg, err := loadProject(); err == nil && cfg.Forward.GPGAgent {
+		host.GPGForward = true
+		if inst, err := lookupInstance(client, cfg.Container.Name, projectDir); err == nil && inst != nil && inst.Status == "Running" {
+			name := cfg.Container.Name
+			host.Container = name
+			host.SignTest = func() error {
+				return forward.TestSigning(client, name, os.Getenv("USER"))
+			}
+		}
+	}
+
+	results := doctor.Run(host, doctor.Checks())
+
+	if err := report.Result(results, func() error {
+		printDoctor(results)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if failed := doctor.Failed(results); failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// printDoctor shows each check with its fix, if it didn't pass
+func printDoctor(results []doctor.Result) {
+	styles := ui.NewStyles()
+
+	fmt.Println(styles.Header("Igloo Doctor"))
+	fmt.Println()
+	for _, r := range results {
+		line := fmt.Sprintf("%-16s %s", r.Name, r.Message)
+		switch r.Status {
+		case doctor.StatusOK:
+			fmt.Println(styles.Success(line))
+		case doctor.StatusWarn:
+			fmt.Println(styles.Warning(line))
+		case doctor.StatusFail:
+			fmt.Println(styles.Error(line))
+		default:
+			fmt.Println(styles.Label("- " + line))
+		}
+		if r.Fix != "" && r.Status != doctor.StatusOK {
+			fmt.Printf("  %s %s\n", styles.Label("Fix:"), r.Fix)
+		}
+	}
+}
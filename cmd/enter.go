@@ -1,142 +1,344 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
+	"sync"
 
 	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/dbus"
+	"github.com/frostyard/igloo/internal/forward"
+	"github.com/frostyard/igloo/internal/hostopen"
+	"github.com/frostyard/igloo/internal/hostsock"
 	"github.com/frostyard/igloo/internal/incus"
+	"github.com/frostyard/igloo/internal/script"
+	"github.com/frostyard/igloo/internal/shell"
 	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
 func enterCmd() *cobra.Command {
+	var keepGoing bool
+	var dryRun bool
+	var rollback bool
+
 	cmd := &cobra.Command{
 		Use:   "enter",
 		Short: "Enter the igloo development environment",
 		Long: `Enter opens an interactive shell in the igloo container.
 If the container is not running, it will be started first.
-If the .igloo configuration has changed, you will be prompted to rebuild.`,
+If the .igloo configuration has changed, you will be prompted to rebuild.
+
+Enter can be run from any subdirectory of the project; the shell starts in the
+matching directory inside the container.`,
 		Example: `  # Enter the igloo environment
-  igloo enter`,
+  igloo enter
+
+  # Show the incus commands enter would run, without running them
+  igloo enter --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runEnter()
+			return runEnter(cmd.Context(), provisionOptions{keepGoing: keepGoing, dryRun: dryRun, rollback: rollback})
 		},
 	}
 
+	cmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Run all init scripts even if some fail, then print a summary")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Delete the container if provisioning fails instead of keeping it to resume later")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the incus commands and cloud-init that would be used without changing anything")
+
 	return cmd
 }
 
-func runEnter() error {
-	styles := ui.NewStyles()
-
-	// Load config
-	cfg, err := config.Load(config.ConfigPath())
+func runEnter(ctx context.Context, opts provisionOptions) error {
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w\nRun 'igloo init' to create a new environment", err)
+		return err
 	}
 
-	client := incus.NewClient()
+	client := newClient()
+	client.SetDryRun(opts.dryRun)
 
 	// Check if instance exists, provision if not
-	exists, err := client.InstanceExists(cfg.Container.Name)
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
 	}
+	exists := inst != nil
 
-	if exists {
+	// A container whose provisioning failed or was interrupted can't be
+	// entered until the remaining steps have run
+	if exists && inst.Provision.Incomplete() {
+		report.Warning(fmt.Sprintf("Provisioning of %s did not finish (stopped at step %s).", cfg.Container.Name, inst.Provision.Step))
+		resume := true
+		if opts.dryRun {
+			report.Info(fmt.Sprintf("Dry run: would ask to resume provisioning from step %s; showing the resume", inst.Provision.Step))
+		} else if resume, err = prompter.Confirm(fmt.Sprintf("Resume provisioning from step %s?", inst.Provision.Step)); err != nil {
+			return err
+		}
+		if !resume {
+			return fmt.Errorf("container %s is incomplete; run 'igloo enter' to resume or 'igloo remove' to start over", cfg.Container.Name)
+		}
+		if err := provisionContainer(ctx, projectDir, cfg, opts); err != nil {
+			return fmt.Errorf("failed to provision container: %w", err)
+		}
+		storeConfigHash(projectDir, cfg.Container.Name, opts.dryRun)
+	} else if exists {
 		// Check if config has changed since last provision
-		changed, currentHash, err := config.ConfigChanged(cfg.Container.Name)
+		changed, currentHash, err := config.ConfigChanged(projectDir, cfg.Container.Name)
 		if err != nil {
-			fmt.Println(styles.Warning(fmt.Sprintf("Could not check for config changes: %v", err)))
+			report.Warning(fmt.Sprintf("Could not check for config changes: %v", err))
 		} else if changed {
-			fmt.Println(styles.Warning("Configuration in .igloo/ has changed since last provision."))
-			fmt.Print(styles.Info("Rebuild container to apply changes? [y/N]: "))
-
-			reader := bufio.NewReader(os.Stdin)
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(strings.ToLower(response))
+			report.Warning("Configuration in .igloo/ has changed since last provision.")
+			rebuild := false
+			if opts.dryRun {
+				report.Info("Dry run: would ask to rebuild the container, which removes and provisions it again; entering it as it is")
+			} else if rebuild, err = prompter.Confirm("Rebuild container to apply changes?"); err != nil {
+				return err
+			}
 
-			if response == "y" || response == "yes" {
-				fmt.Println(styles.Info("Removing old container..."))
+			if rebuild {
+				report.Info("Removing old container...")
 				if err := client.Delete(cfg.Container.Name, true); err != nil {
 					return fmt.Errorf("failed to remove container: %w", err)
 				}
 				exists = false
-			} else {
+			} else if !opts.dryRun {
 				// Update stored hash to current so we don't keep asking
 				if err := config.StoreHash(cfg.Container.Name, currentHash); err != nil {
-					fmt.Println(styles.Warning(fmt.Sprintf("Could not update config hash: %v", err)))
+					report.Warning(fmt.Sprintf("Could not update config hash: %v", err))
 				}
 			}
-		} else if currentHash != "" {
+		} else if currentHash != "" && !opts.dryRun {
 			// No stored hash yet (first run with existing container) - store it now
 			storedHash, _ := config.GetStoredHash(cfg.Container.Name)
 			if storedHash == "" {
 				if err := config.StoreHash(cfg.Container.Name, currentHash); err != nil {
-					fmt.Println(styles.Warning(fmt.Sprintf("Could not store config hash: %v", err)))
+					report.Warning(fmt.Sprintf("Could not store config hash: %v", err))
 				}
 			}
 		}
 	}
 
 	if !exists {
-		fmt.Println(styles.Info("Container does not exist, provisioning..."))
-		if err := provisionContainer(cfg); err != nil {
+		report.Info("Container does not exist, provisioning...")
+		if err := provisionContainer(ctx, projectDir, cfg, opts); err != nil {
 			return fmt.Errorf("failed to provision container: %w", err)
 		}
 
-		// Store the config hash after successful provision
-		currentHash, err := config.HashConfigDir()
-		if err == nil {
-			if err := config.StoreHash(cfg.Container.Name, currentHash); err != nil {
-				fmt.Println(styles.Warning(fmt.Sprintf("Could not store config hash: %v", err)))
+		storeConfigHash(projectDir, cfg.Container.Name, opts.dryRun)
+	}
+
+	if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+		return err
+	}
+
+	// Land in the container directory matching the host's current directory
+	username := os.Getenv("USER")
+	workDir := containerWorkDir(cfg, username, projectDir)
+
+	if !opts.dryRun {
+		if err := config.RecordEnter(cfg.Container.Name, projectDir, cfg.Container.Image); err != nil {
+			report.Warning(fmt.Sprintf("Could not update igloo registry: %v", err))
+		}
+	}
+
+	// Open URLs and files from the container on the host while the shell runs
+	if cfg.Open.Enabled && !opts.dryRun {
+		stop, err := serveHostOpen(client, cfg, username, projectDir)
+		if err != nil {
+			report.Warning(fmt.Sprintf("Opening URLs on the host is unavailable: %v", err))
+		} else {
+			defer stop()
+		}
+	}
+
+	// Run allowlisted host commands for 'igloo host-exec' while the shell runs
+	if cfg.HostExec.Enabled() && !opts.dryRun {
+		stop, err := serveHostExec(client, cfg, username, projectDir)
+		if err != nil {
+			report.Warning(fmt.Sprintf("Running host commands is unavailable: %v", err))
+		} else {
+			defer stop()
+		}
+	}
+
+	report.Info(fmt.Sprintf("Entering %s...", cfg.Container.Name))
+
+	// Execute interactive shell
+	if err := client.ExecInteractive(cfg.Container.Name, username, workDir, shell.Env(cfg.Shell, cfg.Container.Name, projectDir)); err != nil {
+		return fmt.Errorf("failed to enter container: %w", err)
+	}
+
+	if opts.dryRun {
+		report.Info("Dry run: nothing was changed")
+	}
+
+	return nil
+}
+
+// serveHostOpen starts the host handler for the container's xdg-open shim
+// and points the shim's socket at it. The returned function stops the
+// handler and removes any loopback ports it forwarded.
+func serveHostOpen(client *incus.Client, cfg *config.IglooConfig, username, projectDir string) (func(), error) {
+	name := cfg.Container.Name
+
+	// Any scheme can launch a host program that handles it, so ones beyond
+	// the defaults need the same approval as host commands
+	schemes, err := approveAllowlist(client, name, hostopen.SchemesKey, "open", "open %s URLs on the host", cfg.Open.AllowedSchemes(), config.DefaultOpenSchemes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only files in the project can be opened: ~/host would let the container
+	// have the host open anything in the home directory
+	var mappings []config.PathMapping
+	if cfg.Mounts.Project {
+		mappings = append(mappings, config.PathMapping{Host: projectDir, Container: cfg.Mounts.ProjectMountPath(username, projectDir)})
+	}
+
+	var mu sync.Mutex
+	var ports []string
+	handler := &hostopen.Handler{
+		Schemes:  schemes,
+		Mappings: mappings,
+		Open:     hostopen.XDGOpen,
+		Forward: func(port string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			device := hostopen.PortDevice(port)
+			if exists, err := client.DeviceExists(name, device); err != nil || exists {
+				return err
+			}
+			addr := "tcp:127.0.0.1:" + port
+			if err := client.AddDevice(name, device, map[string]string{
+				"type": "proxy", "listen": addr, "connect": addr, "bind": "host",
+			}); err != nil {
+				return err
+			}
+			ports = append(ports, device)
+			report.Info(fmt.Sprintf("Forwarding port %s of %s to the host's loopback until you leave the shell", port, name))
+			return nil
+		},
+	}
+
+	stop, err := hostsock.Serve(client, name, hostopen.Device, hostopen.ContainerSocket(os.Getuid()), handler.Serve)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		stop()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, device := range ports {
+			if err := client.RemoveDevice(name, device); err != nil {
+				report.Warning(fmt.Sprintf("Could not remove port forward %s: %v", device, err))
 			}
 		}
+	}, nil
+}
+
+// storeConfigHash records the config hash after a successful provision
+func storeConfigHash(projectDir, name string, dryRun bool) {
+	currentHash, err := config.HashConfigDir(projectDir)
+	if err == nil && !dryRun {
+		if err := config.StoreHash(name, currentHash); err != nil {
+			report.Warning(fmt.Sprintf("Could not store config hash: %v", err))
+		}
 	}
+}
+
+// ensureRunning starts the instance if it is stopped, waits for it to be ready,
+// runs the project's on-start hooks, and refreshes host resources that can
+// change between sessions. Progress messages are written to r.
+func ensureRunning(ctx context.Context, client *incus.Client, projectDir string, cfg *config.IglooConfig, r *ui.Reporter) error {
+	name := cfg.Container.Name
 
 	// Check if instance is running
-	running, err := client.IsRunning(cfg.Container.Name)
+	running, err := client.IsRunning(name)
 	if err != nil {
 		return fmt.Errorf("failed to check instance status: %w", err)
 	}
 
 	if !running {
-		fmt.Println(styles.Info("Starting container..."))
-		if err := client.Start(cfg.Container.Name); err != nil {
+		r.Info("Starting container...")
+		if err := client.Start(ctx, name); err != nil {
 			return fmt.Errorf("failed to start instance: %w", err)
 		}
 
 		// Wait for cloud-init if container was stopped
-		fmt.Println(styles.Info("Waiting for container to be ready..."))
-		if err := client.WaitForCloudInit(cfg.Container.Name); err != nil {
-			fmt.Println(styles.Warning("Cloud-init wait timed out, continuing anyway..."))
+		r.Info("Waiting for container to be ready...")
+		if err := client.WaitForCloudInit(ctx, name); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.Warning("Cloud-init wait timed out, continuing anyway...")
 		}
+
+		runStartHooks(ctx, client, projectDir, cfg, r)
 	}
 
 	// Update Xauthority mount if necessary (file path can change on Wayland)
-	if err := client.UpdateXauthority(cfg.Container.Name); err != nil {
-		fmt.Println(styles.Warning(fmt.Sprintf("Could not update Xauthority: %v", err)))
+	if err := client.UpdateXauthority(name); err != nil {
+		r.Warning(fmt.Sprintf("Could not update Xauthority: %v", err))
 	}
 
-	// Get user info
-	username := os.Getenv("USER")
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+	updateForwards(client, cfg, r)
+
+	return nil
+}
+
+// updateForwards points the forwarded agent, sound and session bus sockets at
+// the host's current ones, which can change between login sessions, and
+// brings the container's copy of the host's public keys up to date
+func updateForwards(client *incus.Client, cfg *config.IglooConfig, r *ui.Reporter) {
+	if cfg.Forward.SSHAgent {
+		if err := forward.SSHAgent(client, cfg.Container.Name); err != nil {
+			r.Warning(fmt.Sprintf("SSH agent not forwarded: %v", err))
+		}
+	}
+	if cfg.Forward.GPGAgent {
+		if err := forward.GPGAgent(client, cfg.Container.Name); err != nil {
+			r.Warning(fmt.Sprintf("gpg-agent not forwarded: %v", err))
+		} else if _, err := forward.RefreshPublicKeys(client, cfg.Container.Name, os.Getenv("USER")); err != nil {
+			r.Warning(fmt.Sprintf("Could not import public keys: %v", err))
+		}
+	}
+	if cfg.Audio.Enabled {
+		if err := forward.Audio(client, cfg.Container.Name); err != nil {
+			r.Warning(fmt.Sprintf("Audio not forwarded: %v", err))
+		}
 	}
-	projectName := filepath.Base(cwd)
-	workDir := fmt.Sprintf("/home/%s/workspace/%s", username, projectName)
+	if cfg.DBus.Enabled {
+		if err := dbus.Configure(client, cfg.Container.Name, cfg.DBus.Filtered()); err != nil {
+			r.Warning(fmt.Sprintf("D-Bus session bus not forwarded: %v", err))
+		}
+	}
+}
 
-	fmt.Println(styles.Info(fmt.Sprintf("Entering %s...", cfg.Container.Name)))
+// runStartHooks runs the scripts in .igloo/scripts/on-start. A failing hook
+// is reported but doesn't stop the container from being used.
+func runStartHooks(ctx context.Context, client *incus.Client, projectDir string, cfg *config.IglooConfig, r *ui.Reporter) {
+	runner := newScriptRunner(client, cfg, projectDir)
+	runner.SetPhase(script.PhaseStart)
+	runner.SetOptions(config.ScriptsConfig{
+		Timeout:   cfg.Scripts.Timeout,
+		Retries:   cfg.Scripts.Retries,
+		KeepGoing: true,
+	})
 
-	// Execute interactive shell
-	if err := client.ExecInteractive(cfg.Container.Name, username, workDir); err != nil {
-		return fmt.Errorf("failed to enter container: %w", err)
+	hooks, err := runner.GetScripts()
+	if err != nil {
+		r.Warning(fmt.Sprintf("Could not read on-start hooks: %v", err))
+		return
+	}
+	if len(hooks) == 0 {
+		return
 	}
 
-	return nil
+	r.Info("Running on-start hooks...")
+	if _, err := runner.RunScripts(ctx); err != nil {
+		r.Warning(fmt.Sprintf("On-start hooks failed: %v", err))
+	}
 }
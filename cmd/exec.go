@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/frostyard/igloo/internal/shell"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func execCmd() *cobra.Command {
+	var asRoot bool
+	var cwd string
+
+	cmd := &cobra.Command{
+		Use:   "exec [flags] -- command [args...]",
+		Short: "Run a command in the igloo development environment",
+		Long: `Exec runs a non-interactive command in the igloo container as your user,
+with the same environment as 'igloo enter'. If the container is stopped, it
+will be started first.
+
+Standard input, output and error are forwarded, and igloo exits with the
+command's exit code, so exec can be used from host scripts, editors and git hooks.`,
+		Example: `  # Run the test suite inside the igloo
+  igloo exec -- make test
+
+  # Run a command as root
+  igloo exec --root -- apt-get install -y jq
+
+  # Run a command in a specific directory inside the container
+  igloo exec --cwd /tmp -- ls -la`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExec(cmd.Context(), asRoot, cwd, args)
+		},
+	}
+
+	// Stop flag parsing at the first argument so 'igloo exec ls -la' works without --
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().BoolVar(&asRoot, "root", false, "Run the command as root")
+	cmd.Flags().StringVar(&cwd, "cwd", "", "Working directory inside the container (default: matches the current directory)")
+
+	return cmd
+}
+
+func runExec(ctx context.Context, asRoot bool, cwd string, command []string) error {
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+
+	if _, err := provisionedInstance(client, cfg.Container.Name, projectDir); err != nil {
+		return err
+	}
+
+	// Keep stdout clean for the command's own output, including anything
+	// printed by on-start hooks
+	client.SetOutput(os.Stderr)
+	if err := ensureRunning(ctx, client, projectDir, cfg, ui.NewReporter(report.Format(), os.Stderr)); err != nil {
+		return err
+	}
+
+	username := os.Getenv("USER")
+	workDir := cwd
+	if workDir == "" {
+		workDir = containerWorkDir(cfg, username, projectDir)
+	}
+
+	if err := client.ExecCommand(cfg.Container.Name, username, workDir, shell.Env(cfg.Shell, cfg.Container.Name, projectDir), asRoot, command...); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &ExitError{Code: exitErr.ExitCode()}
+		}
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/export"
+	"github.com/frostyard/igloo/internal/incus"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Make an app or command from the igloo available on the host",
+		Long: `Export puts something installed in the igloo container on the host, so it
+can be started without entering the igloo first.
+
+'export app' copies a desktop entry into the host's app menu, with Exec lines
+rewritten to run through 'igloo exec' and the app's icon copied over.
+'export bin' writes a shim to ~/.local/bin that runs the command through
+'igloo exec'. Exports are recorded in the igloo registry and removed with
+'igloo unexport', or when the container is removed or destroyed.`,
+		Example: `  # Add the igloo's VS Code to the host app menu
+  igloo export app code
+
+  # Run the igloo's jq as 'jq' on the host
+  igloo export bin jq
+
+  # Show what this igloo has exported
+  igloo export list`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "app <desktop-id>",
+		Short: "Add a desktop app from the igloo to the host app menu",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExportApp(cmd.Context(), args[0])
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "bin <name>",
+		Short: "Add a host shim in ~/.local/bin for a command in the igloo",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExportBin(cmd.Context(), args[0])
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the apps and commands exported from the igloo",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExportList()
+		},
+	})
+
+	return cmd
+}
+
+func unexportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unexport",
+		Short: "Remove an app or command exported with 'igloo export'",
+		Example: `  # Remove VS Code from the host app menu
+  igloo unexport app code
+
+  # Remove the jq shim
+  igloo unexport bin jq`,
+	}
+
+	for _, kind := range []string{config.ExportApp, config.ExportBin} {
+		cmd.AddCommand(&cobra.Command{
+			Use:   kind + " <name>",
+			Short: fmt.Sprintf("Remove an exported %s", kind),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runUnexport(kind, args[0])
+			},
+		})
+	}
+
+	return cmd
+}
+
+// exportTarget loads the project and makes sure its container is running,
+// since exporting reads files from it
+func exportTarget(ctx context.Context) (string, *config.IglooConfig, *incus.Client, error) {
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	client := newClient()
+	if _, err := provisionedInstance(client, cfg.Container.Name, projectDir); err != nil {
+		return "", nil, nil, err
+	}
+	if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+		return "", nil, nil, err
+	}
+	return projectDir, cfg, client, nil
+}
+
+func runExportApp(ctx context.Context, id string) error {
+	projectDir, cfg, client, err := exportTarget(ctx)
+	if err != nil {
+		return err
+	}
+	name := cfg.Container.Name
+	username := os.Getenv("USER")
+	id = export.DesktopID(id)
+	if strings.Contains(id, "/") {
+		return fmt.Errorf("export app takes a desktop ID, not a path: %s", id)
+	}
+
+	// Read the first desktop entry with this ID, searching like the XDG menu
+	// does, with the user's own entries first
+	dirs := append([]string{fmt.Sprintf("/home/%s/.local/share/applications", username)}, export.DesktopDirs...)
+	findDesktop := `f=$1; shift; for d; do [ -f "$d/$f" ] && exec cat "$d/$f"; done; exit 1`
+	content, err := client.ExecAsUserOutput(name, username, append([]string{"sh", "-c", findDesktop, "sh", id + ".desktop"}, dirs...)...)
+	if err != nil {
+		return fmt.Errorf("no desktop entry %s.desktop in %s (looked in %s)", id, name, strings.Join(dirs, ", "))
+	}
+
+	igloo, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the igloo binary: %w", err)
+	}
+	launcher := export.Launcher{Container: name, Igloo: igloo, ProjectDir: projectDir}
+
+	var files []string
+	if icon, err := exportIcon(client, name, username, export.DesktopIcon(string(content))); err != nil {
+		report.Warning(fmt.Sprintf("Could not copy the icon: %v", err))
+	} else if icon != "" {
+		launcher.Icon = icon
+		files = append(files, icon)
+	}
+
+	desktopPath := export.DesktopPath(name, id)
+	if err := export.WriteFile(desktopPath, []byte(export.RewriteDesktop(string(content), launcher))); err != nil {
+		return fmt.Errorf("failed to write desktop entry: %w", err)
+	}
+	files = append(files, desktopPath)
+
+	if err := recordExport(cfg, projectDir, config.Export{Kind: config.ExportApp, Name: id, Files: files}); err != nil {
+		return err
+	}
+	report.Success(fmt.Sprintf("Exported %s to the host app menu (%s)", id, desktopPath))
+	return nil
+}
+
+// exportIcon copies an app's icon out of the container and returns its host
+// path. Icons named from a theme are looked up the way the desktop would,
+// preferring a scalable one.
+func exportIcon(client *incus.Client, name, username, icon string) (string, error) {
+	if icon == "" {
+		return "", nil
+	}
+
+	source := icon
+	if !filepath.IsAbs(icon) {
+		args := append([]string{"find"}, export.IconDirs...)
+		args = append(args, "(", "-name", icon+".svg", "-o", "-name", icon+".png", "-o", "-name", icon+".xpm", ")")
+		// find fails if any of the directories is missing; its output is still usable
+		output, _ := client.ExecAsUserOutput(name, username, args...)
+		source = export.BestIcon(strings.Fields(string(output)))
+		if source == "" {
+			return "", fmt.Errorf("icon %s not found in the container", icon)
+		}
+	}
+
+	dest := export.IconPath(name, source)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	if err := client.PullFile(name, source, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+func runExportBin(ctx context.Context, command string) error {
+	projectDir, cfg, client, err := exportTarget(ctx)
+	if err != nil {
+		return err
+	}
+	name := cfg.Container.Name
+
+	if strings.Contains(command, "/") {
+		return fmt.Errorf("export bin takes a command name, not a path: %s", command)
+	}
+	if _, err := client.ExecAsUserOutput(name, os.Getenv("USER"), "sh", "-c", `command -v "$1"`, "sh", command); err != nil {
+		return fmt.Errorf("command %s not found in %s", command, name)
+	}
+
+	igloo, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the igloo binary: %w", err)
+	}
+
+	// The shim may replace an earlier export of the same command from this
+	// igloo, but not another igloo's, whose unexport would delete it, and
+	// never a file the user put there
+	binPath := export.BinPath(command)
+	if err := export.WriteBin(binPath, name, export.BinShim(name, command, igloo, projectDir)); err != nil {
+		return err
+	}
+
+	if err := recordExport(cfg, projectDir, config.Export{Kind: config.ExportBin, Name: command, Files: []string{binPath}}); err != nil {
+		return err
+	}
+	report.Success(fmt.Sprintf("Exported %s to %s", command, binPath))
+
+	if !slices.Contains(filepath.SplitList(os.Getenv("PATH")), filepath.Dir(binPath)) {
+		report.Warning(fmt.Sprintf("%s is not in your PATH", filepath.Dir(binPath)))
+	}
+	return nil
+}
+
+// recordExport adds an export to the container's registry entry, creating
+// the entry for containers provisioned before the registry existed
+func recordExport(cfg *config.IglooConfig, projectDir string, exp config.Export) error {
+	entry, err := config.GetRegistryEntry(cfg.Container.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read igloo registry: %w", err)
+	}
+	if entry == nil {
+		entry = &config.RegistryEntry{Name: cfg.Container.Name, ProjectPath: projectDir, Image: cfg.Container.Image}
+	}
+	entry.AddExport(exp)
+	if err := config.SaveRegistryEntry(entry); err != nil {
+		return fmt.Errorf("failed to update igloo registry: %w", err)
+	}
+	return nil
+}
+
+func runExportList() error {
+	_, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	entry, err := config.GetRegistryEntry(cfg.Container.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read igloo registry: %w", err)
+	}
+	exports := []config.Export{}
+	if entry != nil && entry.Exports != nil {
+		exports = entry.Exports
+	}
+
+	return report.Result(exports, func() error {
+		if len(exports) == 0 {
+			fmt.Printf("Nothing is exported from %s\n", cfg.Container.Name)
+			return nil
+		}
+		styles := ui.NewStyles()
+		for _, exp := range exports {
+			fmt.Printf("%-4s %-20s %s\n", exp.Kind, exp.Name, styles.Label(strings.Join(exp.Files, ", ")))
+		}
+		return nil
+	})
+}
+
+func runUnexport(kind, exportName string) error {
+	_, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+	if kind == config.ExportApp {
+		exportName = export.DesktopID(exportName)
+	}
+
+	entry, err := config.GetRegistryEntry(cfg.Container.Name)
+	if err != nil {
+		return fmt.Errorf("failed to read igloo registry: %w", err)
+	}
+	var exp *config.Export
+	if entry != nil {
+		exp = entry.RemoveExport(kind, exportName)
+	}
+	if exp == nil {
+		return fmt.Errorf("%s %s is not exported from %s", kind, exportName, cfg.Container.Name)
+	}
+
+	if err := export.Remove(exp.Files); err != nil {
+		return fmt.Errorf("failed to remove exported files: %w", err)
+	}
+	if err := config.SaveRegistryEntry(entry); err != nil {
+		return fmt.Errorf("failed to update igloo registry: %w", err)
+	}
+
+	report.Success(fmt.Sprintf("Removed exported %s %s", kind, exportName))
+	return nil
+}
+
+// removeExports deletes the host files of everything exported from a
+// container that is going away. It reports whether they are all gone.
+func removeExports(name string) bool {
+	entry, err := config.GetRegistryEntry(name)
+	if err != nil {
+		report.Warning(fmt.Sprintf("Could not read igloo registry: %v", err))
+		return false
+	}
+	if entry == nil || len(entry.Exports) == 0 {
+		return true
+	}
+
+	var errs []error
+	for _, exp := range entry.Exports {
+		errs = append(errs, export.Remove(exp.Files))
+	}
+	if err := errors.Join(errs...); err != nil {
+		report.Warning(fmt.Sprintf("Could not remove exported files: %v", err))
+		return false
+	}
+	report.Info(fmt.Sprintf("Removed %d exported app(s) and command(s)", len(entry.Exports)))
+	return true
+}
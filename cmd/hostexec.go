@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/hostexec"
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+	"github.com/spf13/cobra"
+)
+
+func hostExecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "host-exec -- command [args...]",
+		Short: "Run a host command from inside the igloo",
+		Long: `Host-exec runs inside an igloo container and runs a command on the host,
+such as flatpak, podman or the host's incus client. Only commands listed under
+allow in the [host_exec] section of .igloo/igloo.ini can be run, and only while
+'igloo enter' is running on the host. Since the container can edit igloo.ini,
+'igloo enter' asks on the host before allowing a newly listed command. Commands
+are looked up in the host's $PATH, skipping directories the container can
+write to, such as ~/.local/bin when the home directory is mounted.
+
+The command runs in the host directory matching the current directory, when it
+is in the project or ~/host, and in the project directory otherwise. Standard
+input, output and error are forwarded without a terminal, and host-exec exits
+with the command's exit code.`,
+		Example: `  # List host flatpaks from inside the igloo
+  igloo host-exec -- flatpak list
+
+  # Build an image with the host's podman
+  igloo host-exec -- podman build -t api .`,
+		Args:        cobra.MinimumNArgs(1),
+		Annotations: map[string]string{annotationContainer: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHostExec(args)
+		},
+	}
+
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
+func runHostExec(command []string) error {
+	if guest.Detect() == nil {
+		return errors.New("not inside an igloo container; host-exec only works there, so run the command directly")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	sock := hostexec.ContainerSocket(os.Getuid())
+	code, err := hostexec.Run(sock, hostexec.Request{Args: command, Dir: cwd}, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		if errors.Is(err, hostexec.ErrUnavailable) {
+			return err
+		}
+		return fmt.Errorf("failed to run command on host: %w", err)
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// serveHostExec starts the host handler for 'igloo host-exec' and points the
+// container's socket at it. The returned function stops the handler.
+func serveHostExec(client *incus.Client, cfg *config.IglooConfig, username, projectDir string) (func(), error) {
+	allow, err := approveHostExec(client, cfg.Container.Name, cfg.HostExec.Allow)
+	if err != nil {
+		return nil, err
+	}
+	if len(allow) == 0 {
+		return func() {}, nil
+	}
+
+	// Everything mounted into the container can be changed from inside it
+	writable := []string{projectDir, config.SharedStateDir()}
+	if cfg.Mounts.Home {
+		writable = append(writable, os.Getenv("HOME"))
+	}
+
+	handler := &hostexec.Handler{
+		Allow:    allow,
+		Path:     os.Getenv("PATH"),
+		Writable: writable,
+		Mappings: cfg.Mounts.Mappings(username, os.Getenv("HOME"), projectDir),
+		Dir:      projectDir,
+	}
+
+	return hostsock.Serve(client, cfg.Container.Name, hostexec.Device, hostexec.ContainerSocket(os.Getuid()), handler.Serve)
+}
+
+// approveHostExec returns the host commands the container may run. The
+// allowlist in igloo.ini can be edited from inside the container, so commands
+// added to it since they were last approved are confirmed on the host first.
+func approveHostExec(client *incus.Client, name string, allow []string) ([]string, error) {
+	return approveAllowlist(client, name, hostexec.AllowKey, "host_exec", "run %s on the host", allow, nil)
+}
+
+// approveAllowlist returns the entries of an allowlist from section of
+// igloo.ini that the user approved on the host. Entries added since the
+// last approval are confirmed first, unless they are trusted; declined ones
+// are dropped and asked about again next time. The approval is kept in the
+// instance config under key, out of the container's reach. action describes
+// what the entries allow, with %s standing for them.
+func approveAllowlist(client *incus.Client, name, key, section, action string, allow, trusted []string) ([]string, error) {
+	value, err := client.GetConfig(name, key)
+	if err != nil {
+		return nil, err
+	}
+	approved := strings.Split(value, ",")
+
+	var added []string
+	for _, entry := range allow {
+		if !slices.Contains(approved, entry) && !slices.Contains(trusted, entry) {
+			added = append(added, entry)
+		}
+	}
+	if len(added) == 0 {
+		return allow, nil
+	}
+
+	what := fmt.Sprintf(action, strings.Join(added, ", "))
+	report.Warning(fmt.Sprintf("[%s] in .igloo/igloo.ini now lets %s %s. The container can edit that file, so make sure the change is yours.", section, name, what))
+	ok, err := prompter.Confirm(fmt.Sprintf("Allow %s to %s?", name, what))
+	if err != nil || !ok {
+		if err != nil {
+			report.Warning(err.Error())
+		}
+		report.Info(fmt.Sprintf("Not allowing %s; 'igloo enter' asks again next time", strings.Join(added, ", ")))
+		return slices.DeleteFunc(slices.Clone(allow), func(entry string) bool {
+			return slices.Contains(added, entry)
+		}), nil
+	}
+
+	if err := client.SetConfig(name, key, strings.Join(allow, ",")); err != nil {
+		return nil, fmt.Errorf("failed to record approval of [%s]: %w", section, err)
+	}
+	return allow, nil
+}
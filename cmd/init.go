@@ -1,12 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/frostyard/igloo/internal/config"
-	"github.com/frostyard/igloo/internal/ui"
+	"github.com/frostyard/igloo/internal/incus"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +16,9 @@ func initCmd() *cobra.Command {
 	var release string
 	var name string
 	var packages string
+	var keepGoing bool
+	var dryRun bool
+	var rollback bool
 
 	cmd := &cobra.Command{
 		Use:   "init",
@@ -24,7 +28,7 @@ an incus container for development. The container will have:
 
 - A user matching your host UID/GID
 - Your home directory mounted at ~/host
-- The project directory mounted at ~/workspace/<project>
+- The project directory mounted at ~/workspace/<project> (see [mounts] project_path)
 - Display passthrough for GUI applications`,
 		Example: `  # Initialize with host OS defaults
   igloo init
@@ -33,9 +37,12 @@ an incus container for development. The container will have:
   igloo init --distro ubuntu --release questing
 
   # Initialize with custom name and packages
-  igloo init --name myproject-dev --packages "git,curl,vim"`,
+  igloo init --name myproject-dev --packages "git,curl,vim"
+
+  # Show what init would do without creating anything
+  igloo init --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInit(distro, release, name, packages)
+			return runInit(cmd.Context(), distro, release, name, packages, provisionOptions{keepGoing: keepGoing, dryRun: dryRun, rollback: rollback})
 		},
 	}
 
@@ -43,13 +50,14 @@ an incus container for development. The container will have:
 	cmd.Flags().StringVarP(&release, "release", "r", "", "Distribution release (e.g., questing, trixie, 43, current)")
 	cmd.Flags().StringVarP(&name, "name", "n", "", "Container name (default: igloo-<dirname>)")
 	cmd.Flags().StringVarP(&packages, "packages", "p", "", "Comma-separated list of packages to install")
+	cmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Run all init scripts even if some fail, then print a summary")
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Delete the container if provisioning fails instead of keeping it to resume later")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the config, incus commands and cloud-init that would be used without changing anything")
 
 	return cmd
 }
 
-func runInit(distro, release, name, packages string) error {
-	styles := ui.NewStyles()
-
+func runInit(ctx context.Context, distro, release, name, packages string, opts provisionOptions) error {
 	// Check if .igloo directory already exists
 	if _, err := os.Stat(config.ConfigDir); err == nil {
 		return fmt.Errorf(".igloo directory already exists in this project")
@@ -60,7 +68,6 @@ func runInit(distro, release, name, packages string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get current directory: %w", err)
 	}
-	projectName := filepath.Base(cwd)
 
 	// Detect distro/release from host if not specified
 	if distro == "" || release == "" {
@@ -71,7 +78,7 @@ func runInit(distro, release, name, packages string) error {
 		if release == "" {
 			release = hostRelease
 		}
-		fmt.Println(styles.Info(fmt.Sprintf("Detected host OS: %s/%s", distro, release)))
+		report.Info(fmt.Sprintf("Detected host OS: %s/%s", distro, release))
 	}
 
 	// Validate distro/release
@@ -79,9 +86,10 @@ func runInit(distro, release, name, packages string) error {
 		return err
 	}
 
-	// Set default container name
-	if name == "" {
-		name = "igloo-" + projectName
+	// Pick a container name that doesn't collide with another project's
+	name, err = chooseContainerName(newClient(), cwd, name)
+	if err != nil {
+		return err
 	}
 
 	// Build image name
@@ -113,13 +121,26 @@ func runInit(distro, release, name, packages string) error {
 		},
 	}
 
+	if opts.dryRun {
+		data, err := config.Render(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to render config: %w", err)
+		}
+		report.Detail("Would write .igloo/igloo.ini:", string(data))
+		if err := provisionContainer(ctx, cwd, cfg, opts); err != nil {
+			return err
+		}
+		report.Info("Dry run: nothing was changed")
+		return nil
+	}
+
 	// Create .igloo directory and write config file
-	fmt.Println(styles.Info("Creating .igloo directory..."))
+	report.Info("Creating .igloo directory...")
 	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
 		return fmt.Errorf("failed to create .igloo directory: %w", err)
 	}
 
-	fmt.Println(styles.Info("Writing .igloo/igloo.ini..."))
+	report.Info("Writing .igloo/igloo.ini...")
 	if err := config.Write(config.ConfigPath(), cfg); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
@@ -137,7 +158,20 @@ func runInit(distro, release, name, packages string) error {
 # and when re-provisioning with 'igloo enter' (if container doesn't exist).
 #
 # Scripts run as root inside the container. The project directory is mounted
-# at ~/workspace/<project-name>/ so you can access project files.
+# at $IGLOO_WORKSPACE (~/workspace/<project-name>/ by default) so you can
+# access project files.
+#
+# Igloo passes these environment variables to every script:
+#   IGLOO_USER       - your username inside the container
+#   IGLOO_UID        - your user ID
+#   IGLOO_PROJECT    - the project name
+#   IGLOO_WORKSPACE  - where the project is mounted in the container
+#   IGLOO_DISTRO     - the container distribution (e.g., debian)
+#   IGLOO_RELEASE    - the distribution release (e.g., trixie)
+#   IGLOO_CONTAINER  - the container name
+#   IGLOO_PHASE      - the phase the script runs in (e.g., provision)
+#
+# Additional variables can be defined in the [script_env] section of igloo.ini.
 #
 # Common uses:
 #   - Install additional packages: apt-get install -y nodejs npm
@@ -147,12 +181,16 @@ func runInit(distro, release, name, packages string) error {
 #
 # Naming convention: Use numbered prefixes for ordering (e.g., 01-packages.sh, 02-config.sh)
 #
+# Per-script timeout and retries can be set with a header directive:
+#   # igloo: timeout=10m retries=2
+# Defaults for all scripts live in the [scripts] section of igloo.ini.
+#
 # To enable this script, rename it to remove the .example suffix:
 #   mv 00-example.sh.example 00-example.sh
 
 echo "Hello from igloo init script!"
-echo "Container user: $USER"
-echo "Working directory: $(pwd)"
+echo "Container user: $IGLOO_USER"
+echo "Workspace: $IGLOO_WORKSPACE"
 `
 	examplePath := filepath.Join(scriptsDir, "00-example.sh.example")
 	if err := os.WriteFile(examplePath, []byte(exampleScript), 0644); err != nil {
@@ -160,19 +198,56 @@ echo "Working directory: $(pwd)"
 	}
 
 	// Provision the container
-	if err := provisionContainer(cfg); err != nil {
+	if err := provisionContainer(ctx, cwd, cfg, opts); err != nil {
 		return err
 	}
 
 	// Store the config hash for change detection
-	currentHash, err := config.HashConfigDir()
+	currentHash, err := config.HashConfigDir(cwd)
 	if err == nil {
 		if err := config.StoreHash(cfg.Container.Name, currentHash); err != nil {
-			fmt.Println(styles.Warning(fmt.Sprintf("Could not store config hash: %v", err)))
+			report.Warning(fmt.Sprintf("Could not store config hash: %v", err))
 		}
 	}
 
-	fmt.Println(styles.Info("Run 'igloo enter' to start working"))
+	report.Info("Run 'igloo enter' to start working")
 
 	return nil
 }
+
+// chooseContainerName returns the container name to use for a new project.
+// An explicit name is refused if an instance of that name belongs to
+// something else; the default igloo-<dirname> falls back to a suffixed name
+// unique to this project path and user.
+func chooseContainerName(client *incus.Client, projectDir, name string) (string, error) {
+	username := os.Getenv("USER")
+
+	candidates := []string{name}
+	if name == "" {
+		candidates = []string{
+			config.DefaultContainerName(projectDir),
+			config.UniqueContainerName(projectDir, username),
+		}
+	}
+
+	var owner incus.Ownership
+	for i, candidate := range candidates {
+		inst, err := client.GetInstance(candidate)
+		if err != nil {
+			return "", fmt.Errorf("failed to check instance: %w", err)
+		}
+		if inst == nil || inst.Owner.Matches(projectDir, username) {
+			if i > 0 {
+				report.Warning(fmt.Sprintf("Container %s is already in use, using %s instead", candidates[0], candidate))
+			}
+			return candidate, nil
+		}
+		owner = inst.Owner
+	}
+
+	last := candidates[len(candidates)-1]
+	if !owner.Tagged() {
+		return "", fmt.Errorf("container %s already exists and was not created by igloo for this project\nUse --name to choose a different name", last)
+	}
+	return "", fmt.Errorf("%w\nUse --name to choose a different name", ownershipError(last, owner))
+}
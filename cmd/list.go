@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/incus"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// listEntry describes one igloo for 'igloo list'
+type listEntry struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	ProjectPath string    `json:"project_path,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	Created     time.Time `json:"created,omitzero"`
+	LastEntered time.Time `json:"last_entered,omitzero"`
+	MemoryBytes int64     `json:"memory_bytes"`
+	CPUSeconds  int64     `json:"cpu_seconds"`
+	Processes   int64     `json:"processes"`
+}
+
+func listCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List every igloo on this machine",
+		Long: `List shows every igloo container that igloo knows about, the project
+directory it belongs to, whether it is running, and its resource usage.`,
+		Example: `  # List all igloos
+  igloo list
+
+  # List all igloos as JSON
+  igloo list --json`,
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runList(jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON (same as --output json)")
+
+	return cmd
+}
+
+func runList(jsonOutput bool) error {
+	entries, err := collectIgloos(newClient())
+	if err != nil {
+		return err
+	}
+
+	r := report
+	if jsonOutput {
+		r = ui.NewReporter(ui.FormatJSON, os.Stdout)
+	}
+
+	return r.Result(entries, func() error {
+		return printIgloos(entries)
+	})
+}
+
+// printIgloos renders the igloo list as a table
+func printIgloos(entries []listEntry) error {
+	styles := ui.NewStyles()
+
+	if len(entries) == 0 {
+		fmt.Println(styles.Info("No igloos found. Run 'igloo init' in a project to create one."))
+		return nil
+	}
+
+	fmt.Println(styles.Header("Igloos"))
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "  NAME\tSTATUS\tPROJECT\tIMAGE\tMEMORY\tCPU\tLAST ENTERED")
+	for _, e := range entries {
+		memory, cpu := "-", "-"
+		if e.Status == "running" {
+			memory = formatBytes(e.MemoryBytes)
+			cpu = (time.Duration(e.CPUSeconds) * time.Second).String()
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Name, e.Status, orDash(e.ProjectPath), orDash(e.Image), memory, cpu, formatAge(e.LastEntered))
+	}
+	return w.Flush()
+}
+
+// collectIgloos merges the registry, stored hashes and live incus state
+func collectIgloos(client *incus.Client) ([]listEntry, error) {
+	registry, err := config.ListRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	// Containers provisioned before the registry existed only have a hash
+	hashed, err := config.ListStoredHashes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored hashes: %w", err)
+	}
+
+	instances, err := client.ListInstances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	live := make(map[string]incus.Instance, len(instances))
+	for _, inst := range instances {
+		live[inst.Name] = inst
+	}
+
+	byName := make(map[string]*listEntry)
+	for _, reg := range registry {
+		byName[reg.Name] = &listEntry{
+			Name:        reg.Name,
+			ProjectPath: reg.ProjectPath,
+			Image:       reg.Image,
+			Created:     reg.Created,
+			LastEntered: reg.LastEntered,
+		}
+	}
+	for _, name := range hashed {
+		if _, ok := byName[name]; !ok {
+			byName[name] = &listEntry{Name: name}
+		}
+	}
+
+	entries := make([]listEntry, 0, len(byName))
+	for name, e := range byName {
+		e.Status = "missing"
+		if inst, ok := live[name]; ok {
+			e.Status = "stopped"
+			if inst.Status == "Running" {
+				e.Status = "running"
+			}
+			e.MemoryBytes = inst.MemoryBytes
+			e.CPUSeconds = inst.CPUSeconds
+			e.Processes = inst.Processes
+		}
+		entries = append(entries, *e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// formatBytes renders a byte count in human-readable units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge renders how long ago a time was, or "never" for the zero time
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// loadProject finds the project root by searching up from the current
+// directory for .igloo/, and loads its igloo.ini
+func loadProject() (string, *config.IglooConfig, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	root, err := config.FindProjectRoot(cwd)
+	if inside := guest.Detect(); errors.Is(err, config.ErrNoProject) && inside != nil && inside.Workspace != "" {
+		// Inside the container, the project is wherever it is mounted
+		root, err = config.FindProjectRoot(inside.Workspace)
+	}
+	if err != nil {
+		if errors.Is(err, config.ErrNoProject) {
+			return "", nil, fmt.Errorf("%w\nRun 'igloo init' to create a new environment", err)
+		}
+		return "", nil, fmt.Errorf("failed to find project: %w", err)
+	}
+
+	cfg, err := config.Load(filepath.Join(root, config.ConfigPath()))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return root, cfg, nil
+}
+
+// containerWorkDir returns the directory inside the container that matches
+// the host's current directory, falling back to the project mount root
+func containerWorkDir(cfg *config.IglooConfig, username, projectDir string) string {
+	workspace := cfg.Mounts.ProjectMountPath(username, projectDir)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return workspace
+	}
+	if sub := config.ProjectSubdir(projectDir, cwd); sub != "" {
+		return filepath.Join(workspace, sub)
+	}
+	return workspace
+}
+
+// newClient returns an incus client. With structured output, progress from
+// incus commands goes to stderr so stdout stays machine-readable; with
+// --debug every command is logged to stderr.
+func newClient() *incus.Client {
+	client := incus.NewClient()
+	if report.Structured() {
+		client.SetOutput(os.Stderr)
+	}
+	if debugCommands {
+		client.SetDebug(os.Stderr)
+	}
+	return client
+}
+
+// lookupInstance returns the named instance, or nil if it doesn't exist. It
+// refuses to hand back an instance tagged as belonging to another project or
+// user; untagged instances from older igloo versions are accepted.
+func lookupInstance(client *incus.Client, name, projectDir string) (*incus.Instance, error) {
+	inst, err := client.GetInstance(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check instance: %w", err)
+	}
+	if inst != nil && inst.Owner.Tagged() && !inst.Owner.Matches(projectDir, os.Getenv("USER")) {
+		return nil, fmt.Errorf("%w\nChoose a different name in the [container] section of .igloo/igloo.ini", ownershipError(name, inst.Owner))
+	}
+	return inst, nil
+}
+
+// provisionedInstance returns the named instance, failing unless it exists
+// and finished provisioning
+func provisionedInstance(client *incus.Client, name, projectDir string) (*incus.Instance, error) {
+	inst, err := lookupInstance(client, name, projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if inst == nil {
+		return nil, fmt.Errorf("container %s does not exist\nRun 'igloo enter' to provision it", name)
+	}
+	if inst.Provision.Incomplete() {
+		return nil, fmt.Errorf("container %s is incomplete (provisioning stopped at step %s)\nRun 'igloo enter' to resume provisioning", name, inst.Provision.Step)
+	}
+	return inst, nil
+}
+
+// ownershipError explains that a container belongs to someone else
+func ownershipError(name string, owner incus.Ownership) error {
+	return fmt.Errorf("container %s belongs to %s (user %s), not this project",
+		name, owner.ProjectPath, owner.User)
+}
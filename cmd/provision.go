@@ -1,138 +1,626 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/frostyard/igloo/internal/config"
 	"github.com/frostyard/igloo/internal/display"
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/hostopen"
 	"github.com/frostyard/igloo/internal/incus"
 	"github.com/frostyard/igloo/internal/script"
+	"github.com/frostyard/igloo/internal/sharedstate"
+	"github.com/frostyard/igloo/internal/shell"
 	"github.com/frostyard/igloo/internal/ui"
 )
 
+// provisionOptions holds command-line overrides for provisioning
+type provisionOptions struct {
+	keepGoing bool // Run all init scripts even if some fail
+	dryRun    bool // Print what would be done instead of doing it
+	rollback  bool // Delete the container if provisioning fails
+
+	// Devices to carry over from a rebuilt container, keyed by device name.
+	// Read from the registry, where they survive a failed provision.
+	devices map[string]map[string]string
+}
+
+// Provisioning steps, in order. Each is recorded in the container's
+// user.igloo.step key so a failed or interrupted provision can be resumed.
+const (
+	stepCreate       = "create"
+	stepMountHome    = "mount-home"
+	stepMountProject = "mount-project"
+	stepDevices      = "devices"
+	stepStart        = "start"
+	stepCloudInit    = "cloud-init"
+	stepProjectPath  = "project-path"
+	stepSymlinks     = "symlinks"
+	stepSharedState  = "shared-state"
+	stepDisplay      = "display"
+	stepForward      = "forward"
+	stepHostOpen     = "host-open"
+	stepIgloo        = "igloo"
+	stepShell        = "shell"
+	stepScripts      = "scripts"
+	stepStartHooks   = "on-start"
+)
+
+// provisionSteps lists every step in the order it runs. A container records
+// only steps that apply to it, so resuming finds its place in this order.
+var provisionSteps = []string{
+	stepCreate, stepMountHome, stepMountProject, stepDevices, stepStart,
+	stepCloudInit, stepProjectPath, stepSymlinks, stepSharedState, stepDisplay,
+	stepForward, stepHostOpen, stepIgloo, stepShell, stepScripts, stepStartHooks,
+}
+
+// scriptStepPrefix marks a failed step as a specific init script
+const scriptStepPrefix = "script:"
+
+// provisionTask is one step of provisioning a container
+type provisionTask struct {
+	name  string
+	msg   string
+	run   func() error
+	fatal bool // Stop provisioning if the step fails; otherwise it only warns
+}
+
+// provisioner holds the state shared by the steps of a single provision
+type provisioner struct {
+	ctx          context.Context
+	client       *incus.Client
+	cfg          *config.IglooConfig
+	opts         provisionOptions
+	projectDir   string
+	username     string
+	name         string
+	projectMount string
+	startScript  string // Resume scripts from this one
+	failedScript string // First script that failed, if any
+}
+
 // provisionContainer creates and configures an incus container from an existing igloo.ini
-func provisionContainer(cfg *config.IglooConfig) error {
-	styles := ui.NewStyles()
-	client := incus.NewClient()
+// in projectDir. A container left incomplete by an earlier failed provision is
+// resumed from the step that failed.
+func provisionContainer(ctx context.Context, projectDir string, cfg *config.IglooConfig, opts provisionOptions) error {
+	client := newClient()
+	client.SetDryRun(opts.dryRun)
 
-	// Get current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	projectName := filepath.Base(cwd)
 	username := os.Getenv("USER")
 	name := cfg.Container.Name
-	image := cfg.Container.Image
 
 	// Check if instance already exists
-	exists, err := client.InstanceExists(name)
+	inst, err := lookupInstance(client, name, projectDir)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
 	}
-	if exists {
+	if inst != nil && !inst.Provision.Incomplete() {
 		return nil // Already exists, nothing to do
 	}
 
-	fmt.Println(styles.Info(fmt.Sprintf("Creating container %s from %s...", name, image)))
+	// Devices kept from a rebuilt container, saved by 'igloo rebuild'
+	if entry, err := config.GetRegistryEntry(name); err != nil {
+		report.Warning(fmt.Sprintf("Could not read igloo registry: %v", err))
+	} else if entry != nil {
+		opts.devices = entry.RestoreDevices
+	}
+
+	p := &provisioner{
+		ctx:          ctx,
+		client:       client,
+		cfg:          cfg,
+		opts:         opts,
+		projectDir:   projectDir,
+		username:     username,
+		name:         name,
+		projectMount: cfg.Mounts.ProjectMountPath(username, projectDir),
+	}
+	tasks, err := p.tasks()
+	if err != nil {
+		return err
+	}
+
+	// When resuming, skip the steps that already completed. The container
+	// may have been stopped since, so make sure it's running first.
+	first := 0
+	needStart := false
+	if inst != nil {
+		step := inst.Provision.Step
+		if script, ok := strings.CutPrefix(step, scriptStepPrefix); ok {
+			step = stepScripts
+			p.startScript = script
+		}
+		first = resumeTask(tasks, step)
+		needStart = inst.Status != "Running"
+		report.Info(fmt.Sprintf("Resuming provisioning of %s from step %s...", name, inst.Provision.Step))
+	}
+
+	for i, t := range tasks {
+		if i < first && !(needStart && (t.name == stepStart || t.name == stepCloudInit)) {
+			continue
+		}
+		if t.name != stepCreate {
+			p.markState(incus.StateProvisioning, t.name)
+		}
+		if err := provisionStep(t.name, t.msg, t.run); err != nil && t.fatal {
+			return p.fail(t.name, err)
+		}
+	}
+
+	p.markState(incus.StateReady, "")
+
+	if opts.dryRun {
+		return nil
+	}
+
+	// Record which project this container belongs to
+	if err := config.RegisterContainer(name, projectDir, cfg.Container.Image); err != nil {
+		report.Warning(fmt.Sprintf("Could not update igloo registry: %v", err))
+	}
+
+	report.Success(fmt.Sprintf("Igloo environment '%s' is ready!", name))
+
+	return nil
+}
+
+// resumeTask returns the index of the task to resume from after step, the
+// first one that has not completed. The step may be missing from tasks, when
+// the config or igloo changed since, or unknown. The container exists by
+// then, so creating it is never repeated.
+func resumeTask(tasks []provisionTask, step string) int {
+	rank := slices.Index(provisionSteps, step)
+	for i, t := range tasks {
+		if t.name != stepCreate && slices.Index(provisionSteps, t.name) >= rank {
+			return i
+		}
+	}
+	return len(tasks)
+}
+
+// tasks returns every provisioning step for the container, in order
+func (p *provisioner) tasks() ([]provisionTask, error) {
+	cfg := p.cfg
+	client := p.client
+	name := p.name
+	image := cfg.Container.Image
+	homeDir := fmt.Sprintf("/home/%s", p.username)
+	defaultMount := config.MountsConfig{}.ProjectMountPath(p.username, p.projectDir)
 
 	// Generate cloud-init config
 	cloudInit, err := incus.GenerateCloudInit(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate cloud-init: %w", err)
+		return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
 	}
 
-	// Create instance with cloud-init
-	if err := client.Create(name, image, cloudInit); err != nil {
-		return fmt.Errorf("failed to create instance: %w", err)
-	}
+	tasks := []provisionTask{{
+		// Create instance with cloud-init, tagged with the project and user it
+		// belongs to and marked as provisioning until every step completes
+		name:  stepCreate,
+		msg:   fmt.Sprintf("Creating container %s from %s...", name, image),
+		fatal: true,
+		run: func() error {
+			if p.opts.dryRun {
+				report.Detail("Rendered cloud-init user data:", cloudInit)
+			}
+			keys := incus.NewOwnership(p.projectDir, p.username).Config()
+			maps.Copy(keys, incus.ProvisionState{State: incus.StateProvisioning, Step: stepCreate}.Config())
+			if err := client.Create(name, image, cloudInit, keys); err != nil {
+				return fmt.Errorf("failed to create instance: %w", err)
+			}
+			return nil
+		},
+	}}
 
 	// Add mount devices
 	if cfg.Mounts.Home {
-		homeDir := os.Getenv("HOME")
-		hostPath := fmt.Sprintf("/home/%s/host", username)
-		fmt.Println(styles.Info(fmt.Sprintf("Mounting home directory at %s...", hostPath)))
-		if err := client.AddDiskDevice(name, "home", homeDir, hostPath); err != nil {
-			return fmt.Errorf("failed to add home mount: %w", err)
-		}
+		hostPath := fmt.Sprintf("%s/host", homeDir)
+		tasks = append(tasks, provisionTask{
+			name:  stepMountHome,
+			msg:   fmt.Sprintf("Mounting home directory at %s...", hostPath),
+			fatal: true,
+			run: func() error {
+				if err := p.addDiskDevice("home", os.Getenv("HOME"), hostPath); err != nil {
+					return fmt.Errorf("failed to add home mount: %w", err)
+				}
+				return nil
+			},
+		})
 	}
 
 	if cfg.Mounts.Project {
-		workspacePath := fmt.Sprintf("/home/%s/workspace/%s", username, projectName)
-		fmt.Println(styles.Info(fmt.Sprintf("Mounting project directory at %s...", workspacePath)))
-		if err := client.AddDiskDevice(name, "project", cwd, workspacePath); err != nil {
-			return fmt.Errorf("failed to add project mount: %w", err)
-		}
+		tasks = append(tasks, provisionTask{
+			name:  stepMountProject,
+			msg:   fmt.Sprintf("Mounting project directory at %s...", p.projectMount),
+			fatal: true,
+			run: func() error {
+				if err := p.addDiskDevice("project", p.projectDir, p.projectMount); err != nil {
+					return fmt.Errorf("failed to add project mount: %w", err)
+				}
+				return nil
+			},
+		})
 	}
 
-	// Start the instance first (before display passthrough, so /run/user exists)
-	fmt.Println(styles.Info("Starting container..."))
-	if err := client.Start(name); err != nil {
-		return fmt.Errorf("failed to start instance: %w", err)
+	// Re-attach port forwards and volumes kept from a rebuilt container, before
+	// starting so init scripts can use them
+	if len(p.opts.devices) > 0 {
+		tasks = append(tasks, provisionTask{
+			name:  stepDevices,
+			msg:   "Restoring devices from the previous container...",
+			fatal: true,
+			run: func() error {
+				for _, device := range slices.Sorted(maps.Keys(p.opts.devices)) {
+					if !p.opts.dryRun {
+						if exists, err := client.DeviceExists(name, device); err == nil && exists {
+							continue
+						}
+					}
+					if err := client.AddDevice(name, device, p.opts.devices[device]); err != nil {
+						return fmt.Errorf("failed to restore device %s: %w", device, err)
+					}
+				}
+				return nil
+			},
+		})
 	}
 
-	// Wait for cloud-init to complete (this creates /run/user/<uid>)
-	fmt.Println(styles.Info("Waiting for cloud-init to complete..."))
-	if err := client.WaitForCloudInit(name); err != nil {
-		return fmt.Errorf("cloud-init failed: %w", err)
+	tasks = append(tasks,
+		provisionTask{
+			// Start the instance first (before display passthrough, so /run/user exists)
+			name:  stepStart,
+			msg:   "Starting container...",
+			fatal: true,
+			run: func() error {
+				running, err := client.IsRunning(name)
+				if err != nil {
+					return fmt.Errorf("failed to check instance status: %w", err)
+				}
+				if running {
+					return nil
+				}
+				if err := client.Start(p.ctx, name); err != nil {
+					return fmt.Errorf("failed to start instance: %w", err)
+				}
+				return nil
+			},
+		},
+		provisionTask{
+			// Wait for cloud-init to complete (this creates /run/user/<uid>)
+			name:  stepCloudInit,
+			msg:   "Waiting for cloud-init to complete...",
+			fatal: true,
+			run: func() error {
+				if err := client.WaitForCloudInit(p.ctx, name); err != nil {
+					return fmt.Errorf("cloud-init failed: %w", err)
+				}
+				return nil
+			},
+		},
+	)
+
+	// A project mounted outside ~/workspace gets parent directories created by
+	// incus as root; hand the ones under the user's home back to the user, and
+	// keep ~/workspace/<project> working as a link to the real location
+	if cfg.Mounts.Project && p.projectMount != defaultMount {
+		tasks = append(tasks, provisionTask{
+			name: stepProjectPath,
+			msg:  fmt.Sprintf("Linking %s to %s...", defaultMount, p.projectMount),
+			run: func() error {
+				if parents := parentsUnder(homeDir, p.projectMount); len(parents) > 0 {
+					owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+					if err := client.ExecAsRoot(name, append([]string{"chown", owner}, parents...)...); err != nil {
+						report.Warning(fmt.Sprintf("Could not fix ownership of %s: %v", filepath.Dir(p.projectMount), err))
+					}
+				}
+				if err := client.ExecAsUser(name, p.username, "ln", "-sfn", p.projectMount, defaultMount); err != nil {
+					report.Warning(fmt.Sprintf("Could not link %s to %s: %v", defaultMount, p.projectMount, err))
+					return err
+				}
+				return nil
+			},
+		})
 	}
 
 	// Create symlinks from ~/host/ to ~/
 	if len(cfg.Symlinks) > 0 {
-		fmt.Println(styles.Info("Creating symlinks..."))
-		homeDir := fmt.Sprintf("/home/%s", username)
-		hostDir := fmt.Sprintf("%s/host", homeDir)
-		for _, link := range cfg.Symlinks {
-			// Clean the path and remove leading ~/  or / if present
-			link = filepath.Clean(link)
-			if len(link) >= 2 && link[:2] == "~/" {
-				link = link[2:]
-			} else if len(link) >= 1 && link[0] == '/' {
-				link = link[1:]
-			}
+		tasks = append(tasks, provisionTask{
+			name: stepSymlinks,
+			msg:  "Creating symlinks...",
+			run: func() error {
+				hostDir := fmt.Sprintf("%s/host", homeDir)
+				for _, link := range cfg.Symlinks {
+					// Clean the path and remove leading ~/  or / if present
+					link = filepath.Clean(link)
+					if len(link) >= 2 && link[:2] == "~/" {
+						link = link[2:]
+					} else if len(link) >= 1 && link[0] == '/' {
+						link = link[1:]
+					}
 
-			source := filepath.Join(hostDir, link)
-			target := filepath.Join(homeDir, link)
+					source := filepath.Join(hostDir, link)
+					target := filepath.Join(homeDir, link)
 
-			// Create parent directory if needed, then create symlink
-			// Use -f to force overwrite, and || true to not fail if source doesn't exist
-			parentDir := filepath.Dir(target)
-			cmd := fmt.Sprintf("mkdir -p %s && [ -e %s ] && ln -sf %s %s || true", parentDir, source, source, target)
-			if err := client.ExecAsUser(name, username, "/bin/sh", "-c", cmd); err != nil {
-				fmt.Println(styles.Warning(fmt.Sprintf("Failed to create symlink for %s: %v", link, err)))
-			}
-		}
+					// Create parent directory if needed, then create symlink
+					// Use -f to force overwrite, and || true to not fail if source doesn't exist
+					parentDir := filepath.Dir(target)
+					cmd := fmt.Sprintf("mkdir -p %s && [ -e %s ] && ln -sf %s %s || true", parentDir, source, source, target)
+					if err := client.ExecAsUser(name, p.username, "/bin/sh", "-c", cmd); err != nil {
+						report.Warning(fmt.Sprintf("Failed to create symlink for %s: %v", link, err))
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	// Link directories shared by every igloo into ~/
+	if len(cfg.Shared) > 0 {
+		tasks = append(tasks, provisionTask{
+			name: stepSharedState,
+			msg:  fmt.Sprintf("Linking shared state from %s...", config.SharedStateDir()),
+			run: func() error {
+				if !p.opts.dryRun {
+					for _, path := range cfg.Shared {
+						if err := config.EnsureSharedState(path); err != nil {
+							return err
+						}
+					}
+				}
+				if err := sharedstate.Configure(client, name); err != nil {
+					return err
+				}
+				for _, path := range cfg.Shared {
+					if err := sharedstate.Link(client, name, p.username, path); err != nil {
+						report.Warning(fmt.Sprintf("Failed to link shared state for %s: %v", path, err))
+					}
+				}
+				return nil
+			},
+		})
 	}
 
 	// Add display passthrough (now /run/user/<uid> exists)
 	if cfg.Display.Enabled {
-		fmt.Println(styles.Info("Configuring display passthrough..."))
-		displayType := display.Detect()
-		if err := display.ConfigurePassthrough(client, name, displayType, cfg.Display.GPU); err != nil {
-			fmt.Println(styles.Warning(fmt.Sprintf("Display passthrough configuration failed: %v", err)))
-			fmt.Println(styles.Warning("GUI applications may not work correctly"))
-		}
+		tasks = append(tasks, provisionTask{
+			name: stepDisplay,
+			msg:  "Configuring display passthrough...",
+			run: func() error {
+				displayType := display.Detect()
+				if err := display.ConfigurePassthrough(client, name, displayType, cfg.Display.GPU); err != nil {
+					report.Warning(fmt.Sprintf("Display passthrough configuration failed: %v", err))
+					report.Warning("GUI applications may not work correctly")
+					return err
+				}
+				return nil
+			},
+		})
 	}
 
-	// Run scripts from .igloo/scripts directory if present
-	runner := script.NewRunner(client, name, username, projectName, cwd)
-	scripts, err := runner.GetScripts()
+	// Forward host agents, sound and the session bus (the runtime directory
+	// exists now too)
+	if !cfg.Forward.IsZero() || cfg.Audio.Enabled || cfg.DBus.Enabled {
+		tasks = append(tasks, provisionTask{
+			name: stepForward,
+			msg:  "Forwarding host agents and sockets...",
+			run: func() error {
+				updateForwards(client, cfg, report)
+				return nil
+			},
+		})
+	}
+
+	// Install the xdg-open shim that hands URLs and files to the host
+	if cfg.Open.Enabled {
+		tasks = append(tasks, provisionTask{
+			name: stepHostOpen,
+			msg:  "Installing xdg-open shim...",
+			run: func() error {
+				return hostopen.Install(p.ctx, client, name)
+			},
+		})
+	}
+
+	// Install igloo itself, so it can describe the container from inside and
+	// run 'igloo host-exec' and 'igloo scripts run' there
+	tasks = append(tasks, provisionTask{
+		name: stepIgloo,
+		msg:  fmt.Sprintf("Installing igloo at %s...", guest.BinaryPath),
+		run: func() error {
+			self, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			info := guest.Info{
+				Container:   name,
+				Project:     filepath.Base(p.projectDir),
+				Workspace:   p.projectMount,
+				HostProject: p.projectDir,
+			}
+			return guest.Install(p.ctx, client, info, self)
+		},
+	})
+
+	// Mark interactive shells with the igloo's name
+	tasks = append(tasks, provisionTask{
+		name: stepShell,
+		msg:  fmt.Sprintf("Installing shell prompt indicator at %s...", shell.ProfilePath),
+		run: func() error {
+			return shell.Install(p.ctx, client, name)
+		},
+	})
+
+	// Run scripts from .igloo/scripts and any included library scripts
+	tasks = append(tasks, provisionTask{
+		name:  stepScripts,
+		msg:   "Checking init scripts...",
+		fatal: true,
+		run:   p.runScripts,
+	})
+
+	// The container has just started for the first time, so run the
+	// on-start hooks too
+	tasks = append(tasks, provisionTask{
+		name: stepStartHooks,
+		msg:  "Checking on-start hooks...",
+		run: func() error {
+			runStartHooks(p.ctx, client, p.projectDir, cfg, report)
+			return nil
+		},
+	})
+
+	return tasks, nil
+}
+
+// runScripts runs the project's init scripts and any included library scripts
+func (p *provisioner) runScripts() error {
+	runner := newScriptRunner(p.client, p.cfg, p.projectDir)
+	runner.SetProgress(&scriptProgress{provisioner: p})
+	runner.SetStartAt(p.startScript)
+	scriptOpts := p.cfg.Scripts
+	if p.opts.keepGoing {
+		scriptOpts.KeepGoing = true
+	}
+	runner.SetOptions(scriptOpts)
+
+	results, err := runner.RunScripts(p.ctx)
+	if scriptOpts.KeepGoing && !report.Structured() {
+		printScriptSummary(results)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to check for scripts: %w", err)
+		return fmt.Errorf("init scripts failed: %w", err)
 	}
-	if len(scripts) > 0 {
-		fmt.Println(styles.Info(fmt.Sprintf("Running %d init script(s) from .igloo/scripts/...", len(scripts))))
-		for _, s := range scripts {
-			fmt.Println(styles.Info(fmt.Sprintf("  → %s", s)))
+	return nil
+}
+
+// newScriptRunner returns a runner for the project's scripts in its container
+func newScriptRunner(client *incus.Client, cfg *config.IglooConfig, projectDir string) *script.Runner {
+	username := os.Getenv("USER")
+	runner := script.NewRunner(client, cfg.Container.Name, username, filepath.Base(projectDir), projectDir)
+	runner.SetImage(cfg.Container.Image)
+	runner.SetEnv(cfg.ScriptEnv)
+	runner.SetWorkspacePath(cfg.Mounts.ProjectMountPath(username, projectDir))
+	return runner
+}
+
+// addDiskDevice adds a disk device unless a resumed provision already did
+func (p *provisioner) addDiskDevice(device, source, path string) error {
+	if !p.opts.dryRun {
+		if exists, err := p.client.DeviceExists(p.name, device); err == nil && exists {
+			return nil
 		}
-		if err := runner.RunScripts(); err != nil {
-			return fmt.Errorf("init scripts failed: %w", err)
+	}
+	return p.client.AddDiskDevice(p.name, device, source, path)
+}
+
+// markState records provisioning progress on the container
+func (p *provisioner) markState(state, step string) {
+	if p.opts.dryRun {
+		return
+	}
+	if err := p.client.SetConfigKeys(p.name, incus.ProvisionState{State: state, Step: step}.Config()); err != nil {
+		report.Warning(fmt.Sprintf("Could not record provisioning state: %v", err))
+	}
+}
+
+// fail handles a failed step: the container is either removed, or marked as
+// failed so the next 'igloo enter' can resume from the failed step
+func (p *provisioner) fail(step string, err error) error {
+	if p.opts.dryRun || step == stepCreate {
+		return err // Nothing was created
+	}
+
+	if p.opts.rollback {
+		report.Warning(fmt.Sprintf("Provisioning failed at step %s, removing container %s...", step, p.name))
+		if delErr := p.client.Delete(p.name, true); delErr != nil {
+			report.Warning(fmt.Sprintf("Could not remove container %s: %v", p.name, delErr))
 		}
+		return err
 	}
 
-	fmt.Println(styles.Success(fmt.Sprintf("Igloo environment '%s' is ready!", name)))
+	if step == stepScripts && p.failedScript != "" {
+		step = scriptStepPrefix + p.failedScript
+	}
+	p.markState(incus.StateFailed, step)
+	report.Warning(fmt.Sprintf("Container %s was left incomplete at step %s. Run 'igloo enter' to resume, or 'igloo remove' to start over.", p.name, step))
+	return err
+}
 
-	return nil
+// provisionStep runs fn as a timed step of the provision phase
+func provisionStep(step, msg string, fn func() error) error {
+	s := report.StartStep(script.PhaseProvision, step, msg)
+	err := fn()
+	s.Done(err)
+	return err
+}
+
+// scriptProgress reports each init script as a step of the provision phase,
+// and remembers the first one that failed so provisioning can resume there
+type scriptProgress struct {
+	provisioner *provisioner
+	current     *ui.Step
+}
+
+func (p *scriptProgress) ScriptStarted(s script.Script) {
+	msg := fmt.Sprintf("  → %s", s.Name)
+	if s.Library {
+		msg += " (library)"
+	}
+	p.current = report.StartStep(script.PhaseProvision, "script:"+s.Name, msg)
+}
+
+func (p *scriptProgress) ScriptFinished(r script.Result) {
+	if r.Err != nil && p.provisioner.failedScript == "" {
+		p.provisioner.failedScript = r.Script
+	}
+	if p.current != nil {
+		p.current.Done(r.Err)
+		p.current = nil
+	}
+}
+
+// printScriptSummary prints a pass/fail table for the scripts that were run
+func printScriptSummary(results []script.Result) {
+	if len(results) == 0 {
+		return
+	}
+
+	styles := ui.NewStyles()
+
+	width := len("Script")
+	for _, r := range results {
+		width = max(width, len(r.Script))
+	}
+
+	fmt.Println()
+	fmt.Println(styles.Header("Script Summary"))
+	fmt.Printf("  %-*s  %-6s  %-8s  %s\n", width, "Script", "Result", "Attempts", "Duration")
+	for _, r := range results {
+		status := styles.Success("pass")
+		if r.Err != nil {
+			status = styles.Error("fail")
+		}
+		// Styled status strings are always 6 columns wide, matching "Result"
+		fmt.Printf("  %-*s  %s  %-8d  %s\n", width, r.Script, status, r.Attempts, r.Duration.Round(time.Second))
+		if r.Err != nil {
+			fmt.Printf("  %*s  %s\n", width, "", r.Err)
+		}
+	}
+	fmt.Println()
+}
+
+// parentsUnder returns the directories between base (exclusive) and path
+// (exclusive), outermost first, or nil if path is not under base
+func parentsUnder(base, path string) []string {
+	var parents []string
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, base+"/"); dir = filepath.Dir(dir) {
+		parents = append([]string{dir}, parents...)
+	}
+	return parents
 }
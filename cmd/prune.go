@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/prune"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func pruneCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Clean up orphaned igloo containers, state files and images",
+		Long: `Prune finds igloo resources that no longer belong to a project:
+
+- Your containers whose project directory (or its .igloo config) was
+  deleted, including ones whose provisioning never finished
+- Stored hash and registry files, and exported apps and commands, for
+  containers that no longer exist
+- Cached images igloo downloaded that no container uses
+- igloo-* storage volumes tagged with igloo's user.igloo.* ownership keys
+  for you and a project that was deleted, not attached to any container
+
+Everything found is listed and you are asked to confirm before anything is removed.`,
+		Example: `  # Show what would be removed, then confirm
+  igloo prune
+
+  # Only show what would be removed
+  igloo prune --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrune(dryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed without removing anything")
+
+	return cmd
+}
+
+func runPrune(dryRun bool) error {
+	client := newClient()
+
+	state := prune.State{User: os.Getenv("USER"), ProjectExists: prune.ProjectExists}
+	var err error
+	if state.Registry, err = config.ListRegistry(); err != nil {
+		return fmt.Errorf("failed to read registry: %w", err)
+	}
+	if state.Hashed, err = config.ListStoredHashes(); err != nil {
+		return fmt.Errorf("failed to read stored hashes: %w", err)
+	}
+	if state.Instances, err = client.ListInstances(); err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+	if state.Images, err = client.ListImages(); err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+	if state.Volumes, err = client.ListCustomVolumes(); err != nil {
+		return fmt.Errorf("failed to list storage volumes: %w", err)
+	}
+
+	found := prune.Analyze(state)
+	for _, s := range found.Skipped {
+		report.Warning(fmt.Sprintf("Skipping container %s: could not check project %s: %s", s.Name, s.ProjectPath, s.Error))
+	}
+	if err := report.Result(found, func() error {
+		printPruneReport(found)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if found.Empty() {
+		report.Success("Nothing to prune")
+		return nil
+	}
+
+	if dryRun {
+		report.Info("Dry run: nothing was removed")
+		return nil
+	}
+
+	confirmed, err := prompter.Confirm("Remove everything listed above?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		report.Info("Aborted")
+		return nil
+	}
+
+	failed := 0
+	for _, o := range found.Orphans {
+		report.Info(fmt.Sprintf("Removing container %s...", o.Name))
+		if err := client.Delete(o.Name, true); err != nil {
+			report.Warning(fmt.Sprintf("Could not remove container %s: %v", o.Name, err))
+			failed++
+			continue
+		}
+		failed += removeContainerState(o.Name)
+	}
+
+	for _, name := range found.StaleState {
+		failed += removeContainerState(name)
+	}
+
+	for _, img := range found.Images {
+		report.Info(fmt.Sprintf("Removing image %s (%s)...", shortFingerprint(img.Fingerprint), img.Alias))
+		if err := client.DeleteImage(img.Fingerprint); err != nil {
+			report.Warning(fmt.Sprintf("Could not remove image %s: %v", shortFingerprint(img.Fingerprint), err))
+			failed++
+		}
+	}
+
+	for _, vol := range found.Volumes {
+		report.Info(fmt.Sprintf("Removing volume %s/%s...", vol.Pool, vol.Name))
+		if err := client.DeleteVolume(vol.Pool, vol.Name); err != nil {
+			report.Warning(fmt.Sprintf("Could not remove volume %s/%s: %v", vol.Pool, vol.Name, err))
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d item(s) could not be removed", failed)
+	}
+
+	report.Success("Prune complete")
+	return nil
+}
+
+// printPruneReport lists everything prune found
+func printPruneReport(found *prune.Report) {
+	styles := ui.NewStyles()
+
+	if len(found.Orphans) > 0 {
+		fmt.Println(styles.Header("Orphaned Containers"))
+		for _, o := range found.Orphans {
+			fmt.Printf("  %s %s\n", o.Name, styles.Label("(project "+o.ProjectPath+" is gone)"))
+		}
+		fmt.Println()
+	}
+
+	if len(found.StaleState) > 0 {
+		fmt.Println(styles.Header("Stale State Files"))
+		for _, name := range found.StaleState {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Println()
+	}
+
+	if len(found.Images) > 0 {
+		fmt.Println(styles.Header("Unused Images"))
+		for _, img := range found.Images {
+			fmt.Printf("  %s %s %s\n", shortFingerprint(img.Fingerprint), img.Alias, styles.Label(formatBytes(img.Size)))
+		}
+		fmt.Println()
+	}
+
+	if len(found.Volumes) > 0 {
+		fmt.Println(styles.Header("Unused Volumes"))
+		for _, vol := range found.Volumes {
+			fmt.Printf("  %s/%s\n", vol.Pool, vol.Name)
+		}
+		fmt.Println()
+	}
+}
+
+// removeContainerState deletes the exports, stored hash and registry entry
+// for a container, returning the number of failures. The registry entry is
+// kept while exports remain, since it is the only record of their files.
+func removeContainerState(name string) int {
+	failed := 0
+
+	if err := config.RemoveStoredHash(name); err != nil {
+		report.Warning(fmt.Sprintf("Could not remove stored hash for %s: %v", name, err))
+		failed++
+	}
+	if !removeExports(name) {
+		return failed + 1
+	}
+	if err := config.RemoveRegistryEntry(name); err != nil {
+		report.Warning(fmt.Sprintf("Could not remove registry entry for %s: %v", name, err))
+		failed++
+	}
+	return failed
+}
+
+// shortFingerprint abbreviates an image fingerprint like incus does
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 12 {
+		return fingerprint[:12]
+	}
+	return fingerprint
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/hostopen"
+	"github.com/frostyard/igloo/internal/incus"
+	"github.com/spf13/cobra"
+)
+
+func rebuildCmd() *cobra.Command {
+	var keepVolumes bool
+	var snapshotFirst bool
+	var keepGoing bool
+
+	cmd := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Destroy and reprovision the igloo container",
+		Long: `Rebuild deletes the igloo container and provisions a new one from the
+current .igloo configuration, as if running 'igloo remove' and 'igloo enter'.
+
+The registry entry and any port forwards (tcp or udp proxy devices) are carried
+over to the new container. They are saved in the registry before the old
+container is deleted, so if provisioning fails, 'igloo enter' still restores
+them when it resumes. Custom storage volumes attached to the container are
+re-attached with --keep-volumes; otherwise they are left in the storage pool
+for 'igloo prune' to clean up.`,
+		Example: `  # Rebuild after changing .igloo/
+  igloo rebuild
+
+  # Keep attached storage volumes
+  igloo rebuild --keep-volumes
+
+  # Save a copy of the old container first
+  igloo rebuild --snapshot-first`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRebuild(cmd.Context(), keepVolumes, snapshotFirst, provisionOptions{keepGoing: keepGoing})
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepVolumes, "keep-volumes", false, "Re-attach custom storage volumes to the new container")
+	cmd.Flags().BoolVar(&snapshotFirst, "snapshot-first", false, "Snapshot the old container and keep a stopped copy of it")
+	cmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Run all init scripts even if some fail, then print a summary")
+
+	return cmd
+}
+
+func runRebuild(ctx context.Context, keepVolumes, snapshotFirst bool, opts provisionOptions) error {
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+	name := cfg.Container.Name
+
+	inst, err := lookupInstance(client, name, projectDir)
+	if err != nil {
+		return err
+	}
+
+	if inst != nil {
+		confirmed, err := prompter.Confirm(fmt.Sprintf("Rebuild container %s? Changes outside mounted directories will be lost.", name))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			report.Info("Aborted")
+			return nil
+		}
+
+		if snapshotFirst {
+			backup, err := backupInstance(client, name)
+			if err != nil {
+				return err
+			}
+			report.Success(fmt.Sprintf("Saved the old container as %s", backup))
+		}
+
+		// The old container is the only other record of these, so they must
+		// be saved before it is deleted
+		if err := saveRestoreDevices(name, projectDir, cfg.Container.Image, carriedDevices(inst.Devices, keepVolumes)); err != nil {
+			return fmt.Errorf("failed to save devices to carry over: %w", err)
+		}
+
+		report.Info(fmt.Sprintf("Removing container %s...", name))
+		if err := client.Delete(name, true); err != nil {
+			return fmt.Errorf("failed to remove container: %w", err)
+		}
+	}
+
+	// Provisioning restores the saved devices, and registers the container
+	// again, keeping its creation time and exports
+	if err := provisionContainer(ctx, projectDir, cfg, opts); err != nil {
+		printRestoreDevices(name)
+		return fmt.Errorf("failed to provision container: %w", err)
+	}
+	storeConfigHash(projectDir, name, false)
+
+	report.Success(fmt.Sprintf("Container %s rebuilt", name))
+	return nil
+}
+
+// carriedDevices returns the devices of the old container that provisioning
+// won't recreate and should be carried over: port forwards, except the
+// temporary ones for opening local URLs, and custom volumes if keepVolumes
+// is set
+func carriedDevices(devices map[string]map[string]string, keepVolumes bool) map[string]map[string]string {
+	carried := make(map[string]map[string]string)
+	for name, device := range devices {
+		switch {
+		case hostopen.IsPortDevice(name):
+			continue
+		case incus.IsPortForward(device):
+			carried[name] = device
+		case incus.IsCustomVolume(device):
+			if keepVolumes {
+				carried[name] = device
+			} else {
+				report.Info(fmt.Sprintf("Detaching volume %s (use --keep-volumes to keep it attached)", device["source"]))
+			}
+		}
+	}
+	return carried
+}
+
+// saveRestoreDevices records devices to add to the container that replaces
+// name in its registry entry
+func saveRestoreDevices(name, projectDir, image string, devices map[string]map[string]string) error {
+	entry, err := config.GetRegistryEntry(name)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &config.RegistryEntry{Name: name, ProjectPath: projectDir, Image: image, Created: time.Now()}
+	}
+	if len(devices) == 0 {
+		devices = nil
+	}
+	entry.RestoreDevices = devices
+	return config.SaveRegistryEntry(entry)
+}
+
+// printRestoreDevices lists the devices still waiting to be restored after
+// provisioning failed, so they aren't lost if the container is removed
+func printRestoreDevices(name string) {
+	entry, err := config.GetRegistryEntry(name)
+	if err != nil || entry == nil || len(entry.RestoreDevices) == 0 {
+		return
+	}
+	report.Warning("These devices from the old container are not restored yet; 'igloo enter' adds them when it resumes:")
+	for _, device := range slices.Sorted(maps.Keys(entry.RestoreDevices)) {
+		report.Warning(fmt.Sprintf("  %s: %s", device, formatDevice(entry.RestoreDevices[device])))
+	}
+}
+
+// formatDevice renders a device's config as sorted key=value pairs
+func formatDevice(device map[string]string) string {
+	pairs := make([]string, 0, len(device))
+	for _, key := range slices.Sorted(maps.Keys(device)) {
+		pairs = append(pairs, key+"="+device[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// backupInstance snapshots an instance and copies the snapshot to a new,
+// stopped instance that survives the original being deleted
+func backupInstance(client *incus.Client, name string) (string, error) {
+	snapshot := "pre-rebuild-" + time.Now().Format("20060102-150405")
+	backup := name + "-" + snapshot
+
+	report.Info(fmt.Sprintf("Snapshotting %s...", name))
+	if err := client.CreateSnapshot(name, snapshot); err != nil {
+		return "", fmt.Errorf("failed to snapshot container: %w", err)
+	}
+	if err := client.CopyInstance(name+"/"+snapshot, backup); err != nil {
+		return "", fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+
+	// Untagged, the copy isn't taken for the project's container by lookups,
+	// 'igloo list' or 'igloo prune'
+	untag := incus.Ownership{}.Config()
+	maps.Copy(untag, incus.ProvisionState{}.Config())
+	if err := client.SetConfigKeys(backup, untag); err != nil {
+		return "", fmt.Errorf("failed to untag %s: %w", backup, err)
+	}
+	return backup, nil
+}
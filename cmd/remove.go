@@ -4,8 +4,6 @@ import (
 	"fmt"
 
 	"github.com/frostyard/igloo/internal/config"
-	"github.com/frostyard/igloo/internal/incus"
-	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -21,7 +19,10 @@ This allows you to recreate the environment later with the same settings using '
   igloo remove
 
   # Force remove without stopping first
-  igloo remove --force`,
+  igloo remove --force
+
+  # Remove without asking for confirmation
+  igloo remove --yes`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runRemove(force)
 		},
@@ -33,37 +34,52 @@ This allows you to recreate the environment later with the same settings using '
 }
 
 func runRemove(force bool) error {
-	styles := ui.NewStyles()
-
-	// Load config
-	cfg, err := config.Load(config.ConfigPath())
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	client := incus.NewClient()
+	client := newClient()
 
 	// Check if instance exists
-	exists, err := client.InstanceExists(cfg.Container.Name)
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
 	}
+	exists := inst != nil
 
 	if !exists {
-		fmt.Println(styles.Warning(fmt.Sprintf("Container %s does not exist", cfg.Container.Name)))
+		report.Warning(fmt.Sprintf("Container %s does not exist", cfg.Container.Name))
+		return nil
+	}
+
+	confirmed, err := prompter.Confirm(fmt.Sprintf("Remove container %s?", cfg.Container.Name))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		report.Info("Aborted")
 		return nil
 	}
 
-	fmt.Println(styles.Info(fmt.Sprintf("Removing container %s...", cfg.Container.Name)))
+	report.Info(fmt.Sprintf("Removing container %s...", cfg.Container.Name))
 	if err := client.Delete(cfg.Container.Name, force); err != nil {
 		return fmt.Errorf("failed to remove instance: %w", err)
 	}
 
 	// Remove stored config hash so next enter will re-provision
 	if err := config.RemoveStoredHash(cfg.Container.Name); err != nil {
-		fmt.Println(styles.Warning(fmt.Sprintf("Could not remove stored hash: %v", err)))
+		report.Warning(fmt.Sprintf("Could not remove stored hash: %v", err))
+	}
+
+	// Launchers and shims exported from the container would no longer work
+	removeExports(cfg.Container.Name)
+
+	if err := config.RemoveRegistryEntry(cfg.Container.Name); err != nil {
+		report.Warning(fmt.Sprintf("Could not remove registry entry: %v", err))
 	}
 
-	fmt.Println(styles.Success(fmt.Sprintf("Container %s removed (.igloo preserved)", cfg.Container.Name)))
+	report.Success(fmt.Sprintf("Container %s removed (.igloo preserved)", cfg.Container.Name))
 	return nil
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func restartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restart",
+		Short: "Restart the igloo development environment",
+		Long: `Restart stops the igloo container if it is running and starts it again,
+waiting for it to be ready and running the on-start hooks.`,
+		Example: `  # Restart the igloo environment
+  igloo restart`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRestart(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+func runRestart(ctx context.Context) error {
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+
+	if _, err := provisionedInstance(client, cfg.Container.Name, projectDir); err != nil {
+		return err
+	}
+
+	running, err := client.IsRunning(cfg.Container.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check instance status: %w", err)
+	}
+	if running {
+		report.Info(fmt.Sprintf("Stopping %s...", cfg.Container.Name))
+		if err := client.Stop(cfg.Container.Name); err != nil {
+			return fmt.Errorf("failed to stop instance: %w", err)
+		}
+	}
+
+	if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+		return err
+	}
+
+	report.Success(fmt.Sprintf("Container %s restarted", cfg.Container.Name))
+	return nil
+}
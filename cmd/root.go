@@ -1,11 +1,78 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+// prompter answers confirmation prompts for destructive actions. It is
+// configured from the global --yes, --no and --non-interactive flags.
+var prompter = ui.NewPrompter(ui.AnswerAsk, false)
+
+// report receives progress messages and results from every command. It is
+// configured from the global --output flag.
+var report = ui.NewReporter(ui.FormatText, os.Stdout)
+
+// debugCommands logs every incus command to stderr. It is set by the global
+// --debug flag.
+var debugCommands bool
+
+// annotationContainer marks commands that also work inside an igloo
+// container. Every other command only makes sense on the host.
+const annotationContainer = "igloo.container"
+
+// worksInContainer reports whether a command can run inside an igloo
+// container, either because it or a parent is marked as such, or because it
+// is one of cobra's own help and completion commands
+func worksInContainer(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return true // Only shows help
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[annotationContainer] == "true" {
+			return true
+		}
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+	return false
+}
+
+// ExitError reports that a command should exit with a specific status code.
+// It carries no message of its own; the failing process has already reported
+// whatever went wrong.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the process exit code for an error returned by a command
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
 // RootCmd returns the root command for igloo
 func RootCmd() *cobra.Command {
+	var yes bool
+	var no bool
+	var nonInteractive bool
+	var output string
+	var debug bool
+
 	cmd := &cobra.Command{
 		Use:   "igloo",
 		Short: "Manage incus-based development environments",
@@ -22,23 +89,75 @@ by running them in a container while sharing the project directory and display.`
   # Enter the igloo environment
   igloo enter
 
+  # Run a command in the igloo environment
+  igloo exec -- make test
+
   # Check environment status
   igloo status
 
+  # Check environment status as JSON
+  igloo status -o json
+
+  # List every igloo on this machine
+  igloo list
+
   # Stop the environment
   igloo stop
 
   # Destroy the environment
-  igloo destroy`,
+  igloo destroy
+
+  # Destroy without prompting, e.g. in CI
+  igloo destroy --yes`,
 		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			format, err := ui.ParseFormat(output)
+			if err != nil {
+				return err
+			}
+			report = ui.NewReporter(format, os.Stdout)
+			debugCommands = debug
+
+			answer := ui.AnswerAsk
+			if yes {
+				answer = ui.AnswerYes
+			} else if no {
+				answer = ui.AnswerNo
+			}
+			prompter = ui.NewPrompter(answer, nonInteractive)
+
+			if inside := guest.Detect(); inside != nil && !worksInContainer(cmd) {
+				return fmt.Errorf("cannot run '%s' inside igloo container %s; it only works on the host ('igloo status' describes this igloo)", cmd.CommandPath(), inside.Container)
+			}
+			return nil
+		},
 	}
 
+	cmd.PersistentFlags().BoolVarP(&yes, "yes", "y", false, "Answer yes to all confirmation prompts")
+	cmd.PersistentFlags().BoolVar(&no, "no", false, "Answer no to all confirmation prompts")
+	cmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompt; fail if a confirmation is needed (default when stdin is not a terminal)")
+	cmd.MarkFlagsMutuallyExclusive("yes", "no")
+	cmd.PersistentFlags().BoolVar(&debug, "debug", false, "Log every incus command with its duration and exit status")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", string(ui.FormatText), "Output format: text, json or yaml")
+
 	cmd.AddCommand(initCmd())
 	cmd.AddCommand(enterCmd())
+	cmd.AddCommand(execCmd())
+	cmd.AddCommand(hostExecCmd())
+	cmd.AddCommand(exportCmd())
+	cmd.AddCommand(unexportCmd())
+	cmd.AddCommand(startCmd())
 	cmd.AddCommand(stopCmd())
+	cmd.AddCommand(restartCmd())
+	cmd.AddCommand(rebuildCmd())
 	cmd.AddCommand(removeCmd())
 	cmd.AddCommand(destroyCmd())
 	cmd.AddCommand(statusCmd())
+	cmd.AddCommand(scriptsCmd())
+	cmd.AddCommand(sharedCmd())
+	cmd.AddCommand(listCmd())
+	cmd.AddCommand(pruneCmd())
+	cmd.AddCommand(doctorCmd())
 
 	return cmd
 }
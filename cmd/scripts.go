@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/script"
+	"github.com/spf13/cobra"
+)
+
+func scriptsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "scripts",
+		Short:       "Work with the project's init scripts",
+		Annotations: map[string]string{annotationContainer: "true"},
+	}
+
+	var keepGoing bool
+	run := &cobra.Command{
+		Use:   "run [script...]",
+		Short: "Run init scripts again without reprovisioning",
+		Long: `Run runs the project's init scripts, and any included library scripts, in
+the igloo container as root. With script names, only those scripts run;
+names may leave off the .sh extension.
+
+From the host, the container is started first if needed. Inside the igloo,
+the scripts run right there, through sudo.`,
+		Example: `  # Run every init script again
+  igloo scripts run
+
+  # Run just one script
+  igloo scripts run 20-tools`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScriptsRun(cmd.Context(), args, keepGoing)
+		},
+	}
+	run.Flags().BoolVar(&keepGoing, "keep-going", false, "Run the remaining scripts after one fails")
+	cmd.AddCommand(run)
+
+	return cmd
+}
+
+func runScriptsRun(ctx context.Context, names []string, keepGoing bool) error {
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+	runner := newScriptRunner(client, cfg, projectDir)
+	scriptOpts := cfg.Scripts
+	if keepGoing {
+		scriptOpts.KeepGoing = true
+	}
+
+	if inside := guest.Detect(); inside != nil {
+		// The project is mounted where it was found, and library scripts
+		// were copied in when the container was provisioned
+		runner.SetLocal(true)
+		runner.SetWorkspacePath(projectDir)
+		scriptOpts.Library = script.LibraryDir
+	} else {
+		if _, err := provisionedInstance(client, cfg.Container.Name, projectDir); err != nil {
+			return err
+		}
+		if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+			return err
+		}
+	}
+	runner.SetOptions(scriptOpts)
+	runner.SetOnly(names)
+	if report.Structured() {
+		// Keep stdout for the structured report
+		client.SetOutput(os.Stderr)
+		runner.SetOutput(os.Stderr)
+	}
+
+	results, err := runner.RunScripts(ctx)
+	if !report.Structured() {
+		printScriptSummary(results)
+	}
+	if err != nil {
+		return fmt.Errorf("init scripts failed: %w", err)
+	}
+	if len(results) == 0 {
+		report.Info("No init scripts to run")
+		return nil
+	}
+	report.Success(fmt.Sprintf("Ran %d init script(s)", len(results)))
+	return nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/incus"
+	"github.com/frostyard/igloo/internal/sharedstate"
+	"github.com/frostyard/igloo/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// sharedEntry is a shared state directory linked into the igloo
+type sharedEntry struct {
+	Path     string `json:"path"`      // Relative to ~/ in the container
+	HostPath string `json:"host_path"` // Where it is kept on the host
+}
+
+func sharedCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shared",
+		Short: "Manage directories shared by every igloo",
+		Long: `Shared state is a set of directories, such as editor settings, that every
+igloo linking them sees the same copy of. They live on the host under
+~/.config/igloo/shared_state and are linked into ~/ in the container, so
+installing an extension in one igloo's VS Code makes it available in all of
+them.
+
+Shared paths are listed under [shared_state] in .igloo/igloo.ini. Unlike
+[symlinks], they don't need to exist in your host home directory.`,
+		Example: `  # Share VS Code settings and extensions with other igloos
+  igloo shared add .vscode .config/Code/User
+
+  # Show what this igloo shares
+  igloo shared list
+
+  # Stop sharing; the directory stays on the host for other igloos
+  igloo shared rm .vscode`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the shared directories linked into this igloo",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSharedList()
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add <path>...",
+		Short: "Link shared directories into this igloo, creating them if needed",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSharedAdd(cmd.Context(), args)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rm <path>...",
+		Short: "Stop linking shared directories into this igloo",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSharedRm(cmd.Context(), args)
+		},
+	})
+
+	return cmd
+}
+
+func runSharedList() error {
+	_, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	entries := []sharedEntry{}
+	for _, path := range cfg.Shared {
+		entries = append(entries, sharedEntry{Path: path, HostPath: config.SharedStatePath(path)})
+	}
+
+	return report.Result(entries, func() error {
+		if len(entries) == 0 {
+			fmt.Printf("%s shares no directories\n", cfg.Container.Name)
+			return nil
+		}
+		styles := ui.NewStyles()
+		for _, e := range entries {
+			fmt.Printf("~/%-30s %s\n", e.Path, styles.Label(e.HostPath))
+		}
+		return nil
+	})
+}
+
+// sharedTarget returns the project's container, started, if it has been
+// provisioned, or nil if the change can wait until it is
+func sharedTarget(ctx context.Context, client *incus.Client, projectDir string, cfg *config.IglooConfig) (*incus.Instance, error) {
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
+	if err != nil || inst == nil || inst.Provision.Incomplete() {
+		return nil, err
+	}
+	if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// cleanSharedPaths normalizes the paths given on the command line, where
+// the shell may already have expanded ~/ to the host home directory
+func cleanSharedPaths(args []string) ([]string, error) {
+	home := os.Getenv("HOME")
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		if rel, err := filepath.Rel(home, arg); filepath.IsAbs(arg) && err == nil {
+			arg = rel
+		}
+		path, err := config.CleanSharedPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// saveShared records the shared paths in igloo.ini. When the container has
+// already been updated and was otherwise up to date, the stored config hash
+// follows, so the next 'igloo enter' doesn't offer a rebuild for a change
+// that is already live.
+func saveShared(projectDir, name string, applied bool, shared []string) error {
+	changed, _, err := config.ConfigChanged(projectDir, name)
+	inSync := err == nil && !changed
+
+	if err := config.SaveSharedState(filepath.Join(projectDir, config.ConfigPath()), shared); err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+	if applied && inSync {
+		storeConfigHash(projectDir, name, false)
+	}
+	return nil
+}
+
+func runSharedAdd(ctx context.Context, args []string) error {
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+	paths, err := cleanSharedPaths(args)
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+	inst, err := sharedTarget(ctx, client, projectDir, cfg)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := config.EnsureSharedState(path); err != nil {
+			return fmt.Errorf("failed to create %s: %w", config.SharedStatePath(path), err)
+		}
+	}
+	if inst != nil {
+		if err := sharedstate.Configure(client, cfg.Container.Name); err != nil {
+			return fmt.Errorf("failed to mount shared state: %w", err)
+		}
+	}
+
+	// Paths linked before a failure are still recorded
+	shared := cfg.Shared
+	var linkErr error
+	for _, path := range paths {
+		if inst != nil {
+			if err := sharedstate.Link(client, cfg.Container.Name, os.Getenv("USER"), path); err != nil {
+				linkErr = fmt.Errorf("failed to link ~/%s: %w", path, err)
+				break
+			}
+		}
+		if !slices.Contains(shared, path) {
+			shared = append(shared, path)
+		}
+		report.Success(fmt.Sprintf("Sharing ~/%s from %s", path, config.SharedStatePath(path)))
+	}
+
+	if err := saveShared(projectDir, cfg.Container.Name, inst != nil, shared); err != nil {
+		return err
+	}
+	if linkErr != nil {
+		return linkErr
+	}
+	if inst == nil {
+		report.Info("The container isn't provisioned yet; shared directories are linked when it is")
+	}
+	return nil
+}
+
+func runSharedRm(ctx context.Context, args []string) error {
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+	paths, err := cleanSharedPaths(args)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if !slices.Contains(cfg.Shared, path) {
+			return fmt.Errorf("container %s does not share ~/%s", cfg.Container.Name, path)
+		}
+	}
+
+	client := newClient()
+	inst, err := sharedTarget(ctx, client, projectDir, cfg)
+	if err != nil {
+		return err
+	}
+
+	shared := slices.DeleteFunc(slices.Clone(cfg.Shared), func(p string) bool {
+		return slices.Contains(paths, p)
+	})
+	if inst != nil {
+		for _, path := range paths {
+			if err := sharedstate.Unlink(client, cfg.Container.Name, os.Getenv("USER"), path); err != nil {
+				report.Warning(fmt.Sprintf("Could not remove the link for ~/%s: %v", path, err))
+			}
+		}
+		if len(shared) == 0 {
+			if err := sharedstate.Remove(client, cfg.Container.Name); err != nil {
+				report.Warning(fmt.Sprintf("Could not unmount shared state: %v", err))
+			}
+		}
+	}
+
+	if err := saveShared(projectDir, cfg.Container.Name, inst != nil, shared); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		report.Success(fmt.Sprintf("Stopped sharing ~/%s (kept in %s)", path, config.SharedStatePath(path)))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func startCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start the igloo development environment",
+		Long: `Start boots the igloo container without opening a shell. It waits for the
+container to be ready and runs the on-start hooks in .igloo/scripts/on-start.`,
+		Example: `  # Start the igloo environment in the background
+  igloo start`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStart(cmd.Context())
+		},
+	}
+
+	return cmd
+}
+
+func runStart(ctx context.Context) error {
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
+	if err != nil {
+		return err
+	}
+
+	client := newClient()
+
+	if _, err := provisionedInstance(client, cfg.Container.Name, projectDir); err != nil {
+		return err
+	}
+
+	running, err := client.IsRunning(cfg.Container.Name)
+	if err != nil {
+		return fmt.Errorf("failed to check instance status: %w", err)
+	}
+	if running {
+		report.Info(fmt.Sprintf("Container %s is already running", cfg.Container.Name))
+		return nil
+	}
+
+	if err := ensureRunning(ctx, client, projectDir, cfg, report); err != nil {
+		return err
+	}
+
+	report.Success(fmt.Sprintf("Container %s started", cfg.Container.Name))
+	return nil
+}
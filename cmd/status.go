@@ -5,20 +5,78 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
-	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/dbus"
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/hostexec"
+	"github.com/frostyard/igloo/internal/hostopen"
 	"github.com/frostyard/igloo/internal/incus"
+	"github.com/frostyard/igloo/internal/script"
 	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+// statusResult describes an igloo environment for 'igloo status'
+type statusResult struct {
+	Name      string                       `json:"name"`
+	Image     string                       `json:"image"`
+	Project   string                       `json:"project"`
+	State     string                       `json:"state"` // running, stopped or not-created
+	Owner     *incus.Ownership             `json:"owner,omitempty"`
+	Provision *incus.ProvisionState        `json:"provision,omitempty"`
+	Mounts    []statusMount                `json:"mounts"`
+	Devices   map[string]map[string]string `json:"devices,omitempty"`
+	Display   statusDisplay                `json:"display"`
+	Packages  string                       `json:"packages,omitempty"`
+	Scripts   []statusScript               `json:"scripts"`
+	Symlinks  []string                     `json:"symlinks"`
+	Shared    []string                     `json:"shared_state"`
+
+	ScriptsError string `json:"scripts_error,omitempty"` // Why scripts couldn't be listed
+}
+
+// statusMount is a host directory mounted into the container
+type statusMount struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Path   string `json:"path"`
+}
+
+// statusDisplay describes display passthrough settings
+type statusDisplay struct {
+	Enabled bool `json:"enabled"`
+	GPU     bool `json:"gpu"`
+}
+
+// statusScript is an init script that runs during provisioning
+type statusScript struct {
+	Name    string `json:"name"`
+	Library bool   `json:"library"`
+}
+
+// guestStatusResult describes the igloo that 'igloo status' runs inside
+type guestStatusResult struct {
+	guest.Info
+	Image    string   `json:"image,omitempty"`
+	Services []string `json:"services"` // Host services with a socket in the container
+}
+
 func statusCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show the status of the igloo development environment",
-		Long:  `Status displays information about the igloo container.`,
+		Long: `Status displays information about the igloo container.
+
+Inside an igloo, status describes the igloo itself: its container, where the
+project is on the host and in the container, and which host services are
+connected.`,
 		Example: `  # Show environment status
-  igloo status`,
+  igloo status
+
+  # Show environment status as JSON
+  igloo status -o json`,
+		Annotations: map[string]string{annotationContainer: "true"},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runStatus()
 		},
@@ -28,104 +86,240 @@ func statusCmd() *cobra.Command {
 }
 
 func runStatus() error {
-	styles := ui.NewStyles()
+	if inside := guest.Detect(); inside != nil {
+		return runGuestStatus(*inside)
+	}
 
-	// Load config
-	cfg, err := config.Load(config.ConfigPath())
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	client := incus.NewClient()
+	client := newClient()
+	username := os.Getenv("USER")
 
-	// Check if instance exists
-	exists, err := client.InstanceExists(cfg.Container.Name)
+	// Check if instance exists, and that it belongs to this project
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
 	}
 
-	fmt.Println(styles.Header("Igloo Environment Status"))
-	fmt.Println()
+	status := statusResult{
+		Name:     cfg.Container.Name,
+		Image:    cfg.Container.Image,
+		Project:  projectDir,
+		State:    "not-created",
+		Mounts:   []statusMount{},
+		Display:  statusDisplay{Enabled: cfg.Display.Enabled, GPU: cfg.Display.Enabled && cfg.Display.GPU},
+		Packages: cfg.Packages.Install,
+		Scripts:  []statusScript{},
+		Symlinks: cfg.Symlinks,
+		Shared:   cfg.Shared,
+	}
+	if status.Symlinks == nil {
+		status.Symlinks = []string{}
+	}
+	if status.Shared == nil {
+		status.Shared = []string{}
+	}
 
-	fmt.Printf("  %s %s\n", styles.Label("Name:"), cfg.Container.Name)
-	fmt.Printf("  %s %s\n", styles.Label("Image:"), cfg.Container.Image)
+	if cfg.Mounts.Home {
+		status.Mounts = append(status.Mounts, statusMount{
+			Name:   "home",
+			Source: os.Getenv("HOME"),
+			Path:   fmt.Sprintf("/home/%s/host", username),
+		})
+	}
+	if cfg.Mounts.Project {
+		status.Mounts = append(status.Mounts, statusMount{
+			Name:   "project",
+			Source: projectDir,
+			Path:   cfg.Mounts.ProjectMountPath(username, projectDir),
+		})
+	}
 
-	if !exists {
-		fmt.Printf("  %s %s\n", styles.Label("Status:"), styles.Error("not created"))
-		return nil
+	if inst != nil {
+		status.State = "stopped"
+		if inst.Status == "Running" {
+			status.State = "running"
+		}
+		if inst.Owner.Tagged() {
+			status.Owner = &inst.Owner
+		}
+		if inst.Provision != (incus.ProvisionState{}) {
+			status.Provision = &inst.Provision
+		}
+		status.Devices = inst.Devices
 	}
 
-	// Get instance status
-	running, err := client.IsRunning(cfg.Container.Name)
+	// Include init scripts, including any from the shared library
+	runner := script.NewRunner(client, cfg.Container.Name, username, filepath.Base(projectDir), projectDir)
+	runner.SetOptions(cfg.Scripts)
+	scripts, err := runner.Scripts()
 	if err != nil {
-		return fmt.Errorf("failed to check instance status: %w", err)
+		status.ScriptsError = err.Error()
+	}
+	for _, s := range scripts {
+		// Disabled .example scripts aren't worth listing
+		if filepath.Ext(s.Name) == ".example" {
+			continue
+		}
+		status.Scripts = append(status.Scripts, statusScript{Name: s.Name, Library: s.Library})
+	}
+
+	return report.Result(status, func() error {
+		printStatus(status)
+		return nil
+	})
+}
+
+// runGuestStatus describes the igloo container igloo is running in
+func runGuestStatus(info guest.Info) error {
+	status := guestStatusResult{Info: info, Services: []string{}}
+	if _, cfg, err := loadProject(); err == nil {
+		status.Image = cfg.Container.Image
+	}
+
+	uid := os.Getuid()
+	services := []struct{ name, sock string }{
+		{"open", hostopen.ContainerSocket(uid)},
+		{"host-exec", hostexec.ContainerSocket(uid)},
+		{"dbus", dbus.ContainerSocket(uid)},
+	}
+	for _, s := range services {
+		if _, err := os.Stat(s.sock); err == nil {
+			status.Services = append(status.Services, s.name)
+		}
 	}
 
-	if running {
+	return report.Result(status, func() error {
+		styles := ui.NewStyles()
+		fmt.Println(styles.Header("Inside Igloo"))
+		fmt.Println()
+		fmt.Printf("  %s %s\n", styles.Label("Name:"), status.Container)
+		if status.Image != "" {
+			fmt.Printf("  %s %s\n", styles.Label("Image:"), status.Image)
+		}
+		fmt.Printf("  %s %s\n", styles.Label("Project:"), status.Project)
+		fmt.Printf("  %s %s\n", styles.Label("Workspace:"), status.Workspace)
+		fmt.Printf("  %s %s\n", styles.Label("Host project:"), status.HostProject)
+		if len(status.Services) > 0 {
+			fmt.Printf("  %s %s\n", styles.Label("Host services:"), strings.Join(status.Services, ", "))
+		}
+		return nil
+	})
+}
+
+// printStatus renders the status for humans
+func printStatus(status statusResult) {
+	styles := ui.NewStyles()
+
+	fmt.Println(styles.Header("Igloo Environment Status"))
+	fmt.Println()
+
+	fmt.Printf("  %s %s\n", styles.Label("Name:"), status.Name)
+	fmt.Printf("  %s %s\n", styles.Label("Image:"), status.Image)
+	fmt.Printf("  %s %s\n", styles.Label("Project:"), status.Project)
+
+	switch status.State {
+	case "running":
 		fmt.Printf("  %s %s\n", styles.Label("Status:"), styles.Success("running"))
-	} else {
+	case "stopped":
 		fmt.Printf("  %s %s\n", styles.Label("Status:"), styles.Warning("stopped"))
+	default:
+		fmt.Printf("  %s %s\n", styles.Label("Status:"), styles.Error("not created"))
+		return
+	}
+
+	if status.Owner != nil {
+		fmt.Printf("  %s %s\n", styles.Label("Owner:"), status.Owner.User)
+	} else {
+		fmt.Printf("  %s %s\n", styles.Label("Owner:"), styles.Warning("untagged (created by an older igloo)"))
+	}
+
+	if p := status.Provision; p != nil && p.Incomplete() {
+		msg := fmt.Sprintf("%s at step %s (run 'igloo enter' to resume)", p.State, p.Step)
+		fmt.Printf("  %s %s\n", styles.Label("Provisioning:"), styles.Warning(msg))
 	}
 
 	// Show mount info
 	fmt.Println()
 	fmt.Println(styles.Header("Mounts"))
-	if cfg.Mounts.Home {
-		fmt.Printf("  %s ~/host\n", styles.Label("Home:"))
+	for _, m := range status.Mounts {
+		switch m.Name {
+		case "home":
+			fmt.Printf("  %s ~/host\n", styles.Label("Home:"))
+		case "project":
+			fmt.Printf("  %s %s\n", styles.Label("Project:"), m.Path)
+		}
 	}
-	if cfg.Mounts.Project {
-		fmt.Printf("  %s ~/workspace/<project>\n", styles.Label("Project:"))
+
+	// Show devices other than the mounts above
+	var devices []string
+	for name := range status.Devices {
+		if name != "home" && name != "project" {
+			devices = append(devices, name)
+		}
+	}
+	if len(devices) > 0 {
+		sort.Strings(devices)
+		fmt.Println()
+		fmt.Println(styles.Header("Devices"))
+		for _, name := range devices {
+			fmt.Printf("  %s %s\n", styles.Label(name+":"), status.Devices[name]["type"])
+		}
 	}
 
 	// Show display info
-	if cfg.Display.Enabled {
+	if status.Display.Enabled {
 		fmt.Println()
 		fmt.Println(styles.Header("Display"))
 		fmt.Printf("  %s enabled\n", styles.Label("Passthrough:"))
-		if cfg.Display.GPU {
+		if status.Display.GPU {
 			fmt.Printf("  %s enabled\n", styles.Label("GPU:"))
 		}
 	}
 
 	// Show packages
-	if cfg.Packages.Install != "" {
+	if status.Packages != "" {
 		fmt.Println()
 		fmt.Println(styles.Header("Packages"))
-		fmt.Printf("  %s\n", cfg.Packages.Install)
+		fmt.Printf("  %s\n", status.Packages)
 	}
 
 	// Show init scripts
-	scriptsPath := config.ScriptsPath()
-	if entries, err := os.ReadDir(scriptsPath); err == nil {
-		var scripts []string
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				name := entry.Name()
-				// Skip hidden files and .example files
-				if len(name) > 0 && name[0] != '.' && filepath.Ext(name) != ".example" {
-					scripts = append(scripts, name)
-				}
-			}
-		}
-		if len(scripts) > 0 {
-			sort.Strings(scripts)
-			fmt.Println()
-			fmt.Println(styles.Header("Init Scripts"))
-			for _, s := range scripts {
-				fmt.Printf("  %s\n", s)
+	if status.ScriptsError != "" {
+		fmt.Println()
+		fmt.Println(styles.Warning(fmt.Sprintf("Could not list init scripts: %s", status.ScriptsError)))
+	} else if len(status.Scripts) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Header("Init Scripts"))
+		for _, s := range status.Scripts {
+			if s.Library {
+				fmt.Printf("  %s %s\n", s.Name, styles.Label("(library)"))
+			} else {
+				fmt.Printf("  %s\n", s.Name)
 			}
 		}
 	}
 
 	// Show symlinks
-	if len(cfg.Symlinks) > 0 {
+	if len(status.Symlinks) > 0 {
 		fmt.Println()
 		fmt.Println(styles.Header("Symlinks"))
 		fmt.Printf("  %s ~/host/<path> → ~/<path>\n", styles.Label("Pattern:"))
-		for _, s := range cfg.Symlinks {
+		for _, s := range status.Symlinks {
 			fmt.Printf("  %s\n", s)
 		}
 	}
 
-	return nil
+	// Show shared state
+	if len(status.Shared) > 0 {
+		fmt.Println()
+		fmt.Println(styles.Header("Shared State"))
+		for _, s := range status.Shared {
+			fmt.Printf("  ~/%s\n", s)
+		}
+	}
 }
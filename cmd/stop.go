@@ -3,9 +3,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/frostyard/igloo/internal/config"
-	"github.com/frostyard/igloo/internal/incus"
-	"github.com/frostyard/igloo/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -25,21 +22,20 @@ func stopCmd() *cobra.Command {
 }
 
 func runStop() error {
-	styles := ui.NewStyles()
-
-	// Load config
-	cfg, err := config.Load(config.ConfigPath())
+	// Load config from the project root
+	projectDir, cfg, err := loadProject()
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return err
 	}
 
-	client := incus.NewClient()
+	client := newClient()
 
 	// Check if instance exists
-	exists, err := client.InstanceExists(cfg.Container.Name)
+	inst, err := lookupInstance(client, cfg.Container.Name, projectDir)
 	if err != nil {
-		return fmt.Errorf("failed to check instance: %w", err)
+		return err
 	}
+	exists := inst != nil
 	if !exists {
 		return fmt.Errorf("instance %s does not exist", cfg.Container.Name)
 	}
@@ -50,15 +46,15 @@ func runStop() error {
 		return fmt.Errorf("failed to check instance status: %w", err)
 	}
 	if !running {
-		fmt.Println(styles.Info(fmt.Sprintf("Container %s is already stopped", cfg.Container.Name)))
+		report.Info(fmt.Sprintf("Container %s is already stopped", cfg.Container.Name))
 		return nil
 	}
 
-	fmt.Println(styles.Info(fmt.Sprintf("Stopping %s...", cfg.Container.Name)))
+	report.Info(fmt.Sprintf("Stopping %s...", cfg.Container.Name))
 	if err := client.Stop(cfg.Container.Name); err != nil {
 		return fmt.Errorf("failed to stop instance: %w", err)
 	}
 
-	fmt.Println(styles.Success(fmt.Sprintf("Container %s stopped", cfg.Container.Name)))
+	report.Success(fmt.Sprintf("Container %s stopped", cfg.Container.Name))
 	return nil
 }
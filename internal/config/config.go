@@ -1,10 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -17,6 +20,9 @@ const (
 	ConfigFile = "igloo.ini"
 	// ScriptsDir is the subdirectory within ConfigDir for init scripts
 	ScriptsDir = "scripts"
+	// StartHooksDir is the subdirectory within ScriptsDir for scripts run
+	// every time the container starts
+	StartHooksDir = "on-start"
 )
 
 // ConfigPath returns the full path to the igloo.ini file
@@ -29,6 +35,11 @@ func ScriptsPath() string {
 	return filepath.Join(ConfigDir, ScriptsDir)
 }
 
+// StartHooksPath returns the full path to the on-start hooks directory
+func StartHooksPath() string {
+	return filepath.Join(ConfigDir, ScriptsDir, StartHooksDir)
+}
+
 // TODO: Add support for XDG user config at ~/.config/igloo/config.ini
 // This would allow users to set default distro, packages, display settings, etc.
 // Project-level .igloo/igloo.ini would override these defaults.
@@ -39,7 +50,16 @@ type IglooConfig struct {
 	Packages  PackagesConfig
 	Mounts    MountsConfig
 	Display   DisplayConfig
-	Symlinks  []string // List of paths to symlink from ~/host/ to ~/
+	Audio     AudioConfig
+	DBus      DBusConfig
+	Open      OpenConfig
+	HostExec  HostExecConfig
+	Forward   ForwardConfig
+	Scripts   ScriptsConfig
+	Shell     ShellConfig
+	Symlinks  []string          // List of paths to symlink from ~/host/ to ~/
+	Shared    []string          // Paths under ~/ linked to shared state kept on the host
+	ScriptEnv map[string]string // Extra environment variables passed to init scripts
 }
 
 // ContainerConfig holds container-specific settings
@@ -53,10 +73,43 @@ type PackagesConfig struct {
 	Install string `ini:"install"`
 }
 
+// Project mount locations for MountsConfig.ProjectPath
+const (
+	// ProjectPathWorkspace mounts the project at ~/workspace/<project> (the default)
+	ProjectPathWorkspace = "workspace"
+	// ProjectPathMirror mounts the project at its absolute host path
+	ProjectPathMirror = "mirror"
+)
+
 // MountsConfig holds mount settings
 type MountsConfig struct {
-	Home    bool `ini:"home"`
-	Project bool `ini:"project"`
+	Home        bool   `ini:"home"`
+	Project     bool   `ini:"project"`
+	ProjectPath string `ini:"project_path"` // "workspace", "mirror" or an absolute container path
+}
+
+// ProjectMountPath returns where the project directory is mounted inside the container
+func (m MountsConfig) ProjectMountPath(username, projectDir string) string {
+	switch m.ProjectPath {
+	case "", ProjectPathWorkspace:
+		return fmt.Sprintf("/home/%s/workspace/%s", username, filepath.Base(projectDir))
+	case ProjectPathMirror:
+		return projectDir
+	default:
+		return filepath.Clean(m.ProjectPath)
+	}
+}
+
+// validateProjectPath checks that project_path is a known mode or an absolute path
+func validateProjectPath(path string) error {
+	switch path {
+	case "", ProjectPathWorkspace, ProjectPathMirror:
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("invalid project_path %q: must be %q, %q or an absolute path", path, ProjectPathWorkspace, ProjectPathMirror)
+	}
+	return nil
 }
 
 // DisplayConfig holds display passthrough settings
@@ -65,6 +118,102 @@ type DisplayConfig struct {
 	GPU     bool `ini:"gpu"`
 }
 
+// AudioConfig holds sound passthrough settings
+type AudioConfig struct {
+	Enabled bool `ini:"enabled"` // Forward the host's PipeWire and PulseAudio sockets
+}
+
+// Session bus access modes for DBusConfig.Mode
+const (
+	// DBusFiltered exposes only the desktop portal, notification and secret
+	// service interfaces (the default)
+	DBusFiltered = "filtered"
+	// DBusFull exposes the whole session bus
+	DBusFull = "full"
+)
+
+// DBusConfig holds D-Bus session bus passthrough settings
+type DBusConfig struct {
+	Enabled bool   `ini:"enabled"` // Forward the host's session bus
+	Mode    string `ini:"mode"`    // "filtered" or "full"
+}
+
+// Filtered reports whether the session bus is forwarded through a filter
+func (d DBusConfig) Filtered() bool {
+	return d.Mode != DBusFull
+}
+
+// validateDBusMode checks that mode is a known session bus mode
+func validateDBusMode(mode string) error {
+	switch mode {
+	case "", DBusFiltered, DBusFull:
+		return nil
+	}
+	return fmt.Errorf("invalid dbus mode %q: must be %q or %q", mode, DBusFiltered, DBusFull)
+}
+
+// DefaultOpenSchemes are the URL schemes opened on the host when [open] sets
+// no schemes of its own
+var DefaultOpenSchemes = []string{"http", "https", "mailto"}
+
+// OpenConfig holds settings for opening URLs and files on the host
+type OpenConfig struct {
+	Enabled bool     `ini:"enabled"` // Install the xdg-open/BROWSER shim
+	Schemes []string `ini:"-"`       // URL schemes the host will open
+}
+
+// AllowedSchemes returns the URL schemes the host will open
+func (o OpenConfig) AllowedSchemes() []string {
+	if len(o.Schemes) == 0 {
+		return DefaultOpenSchemes
+	}
+	return o.Schemes
+}
+
+// HostExecConfig holds settings for running host commands from the container
+type HostExecConfig struct {
+	Allow []string `ini:"-"` // Host commands 'igloo host-exec' may run, by name
+}
+
+// Enabled reports whether any host commands are allowed
+func (h HostExecConfig) Enabled() bool {
+	return len(h.Allow) > 0
+}
+
+// ForwardConfig holds settings for forwarding host agents into the container
+type ForwardConfig struct {
+	SSHAgent bool `ini:"ssh_agent"` // Forward the host's $SSH_AUTH_SOCK
+	GPGAgent bool `ini:"gpg_agent"` // Forward the host's gpg-agent for signing
+}
+
+// IsZero reports whether nothing is forwarded
+func (f ForwardConfig) IsZero() bool {
+	return !f.SSHAgent && !f.GPGAgent
+}
+
+// ShellConfig holds settings for interactive shells in the container. The
+// prompt prefix and terminal title are shown unless turned off.
+type ShellConfig struct {
+	HidePrompt bool   `ini:"-"`     // prompt = false: leave the prompt as it is
+	HideTitle  bool   `ini:"-"`     // title = false: leave the terminal title as it is
+	Label      string `ini:"label"` // Name shown instead of the container name
+}
+
+// ScriptsConfig holds init script execution settings.
+// Individual scripts can override Timeout and Retries with header comments.
+type ScriptsConfig struct {
+	Timeout   time.Duration `ini:"timeout"`    // Per-script timeout (0 = no timeout)
+	Retries   int           `ini:"retries"`    // Extra attempts after a failure
+	KeepGoing bool          `ini:"keep_going"` // Run remaining scripts after a failure
+	Library   string        `ini:"library"`    // Optional team script library directory
+	Include   []string      `ini:"-"`          // Library scripts to run with the project's scripts
+}
+
+// isZero reports whether no script settings are configured
+func (s ScriptsConfig) isZero() bool {
+	return s.Timeout == 0 && s.Retries == 0 && !s.KeepGoing && s.Library == "" && len(s.Include) == 0
+}
+
 // Load reads and parses an igloo.ini file
 func Load(path string) (*IglooConfig, error) {
 	cfg, err := ini.Load(path)
@@ -86,20 +235,65 @@ func Load(path string) (*IglooConfig, error) {
 	if err := cfg.Section("mounts").MapTo(&config.Mounts); err != nil {
 		return nil, fmt.Errorf("failed to parse mounts section: %w", err)
 	}
+	if err := validateProjectPath(config.Mounts.ProjectPath); err != nil {
+		return nil, err
+	}
 
 	if err := cfg.Section("display").MapTo(&config.Display); err != nil {
 		return nil, fmt.Errorf("failed to parse display section: %w", err)
 	}
 
+	if err := cfg.Section("audio").MapTo(&config.Audio); err != nil {
+		return nil, fmt.Errorf("failed to parse audio section: %w", err)
+	}
+
+	if err := cfg.Section("dbus").MapTo(&config.DBus); err != nil {
+		return nil, fmt.Errorf("failed to parse dbus section: %w", err)
+	}
+	if err := validateDBusMode(config.DBus.Mode); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Section("open").MapTo(&config.Open); err != nil {
+		return nil, fmt.Errorf("failed to parse open section: %w", err)
+	}
+	config.Open.Schemes = splitList(cfg.Section("open").Key("schemes").String())
+
+	config.HostExec.Allow = splitList(cfg.Section("host_exec").Key("allow").String())
+
+	if err := cfg.Section("forward").MapTo(&config.Forward); err != nil {
+		return nil, fmt.Errorf("failed to parse forward section: %w", err)
+	}
+
+	if err := cfg.Section("shell").MapTo(&config.Shell); err != nil {
+		return nil, fmt.Errorf("failed to parse shell section: %w", err)
+	}
+	config.Shell.HidePrompt = !cfg.Section("shell").Key("prompt").MustBool(true)
+	config.Shell.HideTitle = !cfg.Section("shell").Key("title").MustBool(true)
+
+	if err := cfg.Section("scripts").MapTo(&config.Scripts); err != nil {
+		return nil, fmt.Errorf("failed to parse scripts section: %w", err)
+	}
+	config.Scripts.Include = splitList(cfg.Section("scripts").Key("include").String())
+
 	// Parse symlinks section (comma-separated list)
-	symlinksKey := cfg.Section("symlinks").Key("paths")
-	if symlinksKey != nil && symlinksKey.String() != "" {
-		paths := strings.Split(symlinksKey.String(), ",")
-		for _, p := range paths {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				config.Symlinks = append(config.Symlinks, p)
+	config.Symlinks = splitList(cfg.Section("symlinks").Key("paths").String())
+
+	for _, path := range splitList(cfg.Section("shared_state").Key("paths").String()) {
+		clean, err := CleanSharedPath(path)
+		if err != nil {
+			return nil, err
+		}
+		config.Shared = append(config.Shared, clean)
+	}
+
+	// Parse script_env section (arbitrary KEY = value pairs)
+	if sec, err := cfg.GetSection("script_env"); err == nil {
+		for _, key := range sec.Keys() {
+			if config.ScriptEnv == nil {
+				config.ScriptEnv = make(map[string]string)
 			}
+			config.ScriptEnv[key.Name()] = key.String()
 		}
 	}
 
@@ -108,70 +302,271 @@ func Load(path string) (*IglooConfig, error) {
 
 // Write creates an igloo.ini file with the given configuration
 func Write(path string, config *IglooConfig) error {
+	cfg, err := toINI(config)
+	if err != nil {
+		return err
+	}
+	return cfg.SaveTo(path)
+}
+
+// Render returns the igloo.ini contents for the given configuration
+func Render(config *IglooConfig) ([]byte, error) {
+	cfg, err := toINI(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toINI builds the ini representation of a configuration
+func toINI(config *IglooConfig) (*ini.File, error) {
 	cfg := ini.Empty()
 
 	// Container section
 	containerSec, err := cfg.NewSection("container")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	containerSec.Comment = "Container configuration"
 	if _, err := containerSec.NewKey("image", config.Container.Image); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := containerSec.NewKey("name", config.Container.Name); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Packages section
 	packagesSec, err := cfg.NewSection("packages")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	packagesSec.Comment = "Packages to install in the container"
 	if _, err := packagesSec.NewKey("install", config.Packages.Install); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Mounts section
 	mountsSec, err := cfg.NewSection("mounts")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	mountsSec.Comment = "Host directory mounts"
 	if _, err := mountsSec.NewKey("home", fmt.Sprintf("%t", config.Mounts.Home)); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := mountsSec.NewKey("project", fmt.Sprintf("%t", config.Mounts.Project)); err != nil {
-		return err
+		return nil, err
+	}
+	if config.Mounts.ProjectPath != "" {
+		if _, err := mountsSec.NewKey("project_path", config.Mounts.ProjectPath); err != nil {
+			return nil, err
+		}
 	}
 
 	// Display section
 	displaySec, err := cfg.NewSection("display")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	displaySec.Comment = "Display passthrough settings"
 	if _, err := displaySec.NewKey("enabled", fmt.Sprintf("%t", config.Display.Enabled)); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := displaySec.NewKey("gpu", fmt.Sprintf("%t", config.Display.GPU)); err != nil {
-		return err
+		return nil, err
+	}
+
+	// Audio section
+	if config.Audio.Enabled {
+		audioSec, err := cfg.NewSection("audio")
+		if err != nil {
+			return nil, err
+		}
+		audioSec.Comment = "Sound passthrough settings"
+		if _, err := audioSec.NewKey("enabled", "true"); err != nil {
+			return nil, err
+		}
+	}
+
+	// D-Bus section
+	if config.DBus.Enabled {
+		dbusSec, err := cfg.NewSection("dbus")
+		if err != nil {
+			return nil, err
+		}
+		dbusSec.Comment = "Session bus passthrough settings"
+		if _, err := dbusSec.NewKey("enabled", "true"); err != nil {
+			return nil, err
+		}
+		if config.DBus.Mode != "" {
+			if _, err := dbusSec.NewKey("mode", config.DBus.Mode); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Open section
+	if config.Open.Enabled {
+		openSec, err := cfg.NewSection("open")
+		if err != nil {
+			return nil, err
+		}
+		openSec.Comment = "Open URLs and files on the host"
+		if _, err := openSec.NewKey("enabled", "true"); err != nil {
+			return nil, err
+		}
+		if len(config.Open.Schemes) > 0 {
+			if _, err := openSec.NewKey("schemes", strings.Join(config.Open.Schemes, ", ")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Host exec section
+	if config.HostExec.Enabled() {
+		hostExecSec, err := cfg.NewSection("host_exec")
+		if err != nil {
+			return nil, err
+		}
+		hostExecSec.Comment = "Host commands the container may run with 'igloo host-exec'"
+		if _, err := hostExecSec.NewKey("allow", strings.Join(config.HostExec.Allow, ", ")); err != nil {
+			return nil, err
+		}
+	}
+
+	// Forward section
+	if !config.Forward.IsZero() {
+		forwardSec, err := cfg.NewSection("forward")
+		if err != nil {
+			return nil, err
+		}
+		forwardSec.Comment = "Host agents forwarded into the container"
+		if _, err := forwardSec.NewKey("ssh_agent", fmt.Sprintf("%t", config.Forward.SSHAgent)); err != nil {
+			return nil, err
+		}
+		if _, err := forwardSec.NewKey("gpg_agent", fmt.Sprintf("%t", config.Forward.GPGAgent)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Scripts section
+	if !config.Scripts.isZero() {
+		scriptsSec, err := cfg.NewSection("scripts")
+		if err != nil {
+			return nil, err
+		}
+		scriptsSec.Comment = "Init script execution settings"
+		if config.Scripts.Timeout > 0 {
+			if _, err := scriptsSec.NewKey("timeout", config.Scripts.Timeout.String()); err != nil {
+				return nil, err
+			}
+		}
+		if config.Scripts.Retries > 0 {
+			if _, err := scriptsSec.NewKey("retries", fmt.Sprintf("%d", config.Scripts.Retries)); err != nil {
+				return nil, err
+			}
+		}
+		if config.Scripts.KeepGoing {
+			if _, err := scriptsSec.NewKey("keep_going", "true"); err != nil {
+				return nil, err
+			}
+		}
+		if config.Scripts.Library != "" {
+			if _, err := scriptsSec.NewKey("library", config.Scripts.Library); err != nil {
+				return nil, err
+			}
+		}
+		if len(config.Scripts.Include) > 0 {
+			if _, err := scriptsSec.NewKey("include", strings.Join(config.Scripts.Include, ", ")); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Shell section
+	if config.Shell != (ShellConfig{}) {
+		shellSec, err := cfg.NewSection("shell")
+		if err != nil {
+			return nil, err
+		}
+		if config.Shell.HidePrompt {
+			if _, err := shellSec.NewKey("prompt", "false"); err != nil {
+				return nil, err
+			}
+		}
+		if config.Shell.HideTitle {
+			if _, err := shellSec.NewKey("title", "false"); err != nil {
+				return nil, err
+			}
+		}
+		if config.Shell.Label != "" {
+			if _, err := shellSec.NewKey("label", config.Shell.Label); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Symlinks section
 	if len(config.Symlinks) > 0 {
 		symlinksSec, err := cfg.NewSection("symlinks")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		symlinksSec.Comment = "Symlinks from ~/host/ to ~/ (files/folders that exist on host)"
 		if _, err := symlinksSec.NewKey("paths", strings.Join(config.Symlinks, ", ")); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return cfg.SaveTo(path)
+	// Shared state section
+	if len(config.Shared) > 0 {
+		sharedSec, err := cfg.NewSection("shared_state")
+		if err != nil {
+			return nil, err
+		}
+		sharedSec.Comment = sharedStateComment
+		if _, err := sharedSec.NewKey("paths", strings.Join(config.Shared, ", ")); err != nil {
+			return nil, err
+		}
+	}
+
+	// Script environment section
+	if len(config.ScriptEnv) > 0 {
+		scriptEnvSec, err := cfg.NewSection("script_env")
+		if err != nil {
+			return nil, err
+		}
+		scriptEnvSec.Comment = "Extra environment variables passed to init scripts"
+		keys := make([]string, 0, len(config.ScriptEnv))
+		for k := range config.ScriptEnv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, err := scriptEnvSec.NewKey(k, config.ScriptEnv[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // Remove deletes the config file
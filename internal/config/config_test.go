@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -116,6 +119,172 @@ paths = .gitconfig, .ssh, .config/nvim
 	}
 }
 
+func TestLoad_WithScriptEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "igloo.ini")
+
+	content := `[container]
+image = images:debian/trixie/cloud
+name = test-igloo
+
+[script_env]
+GOPROXY = https://proxy.golang.org
+NODE_VERSION = 22
+`
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"GOPROXY":      "https://proxy.golang.org",
+		"NODE_VERSION": "22",
+	}
+	if len(cfg.ScriptEnv) != len(expected) {
+		t.Errorf("ScriptEnv length = %d, want %d", len(cfg.ScriptEnv), len(expected))
+	}
+	for k, v := range expected {
+		if cfg.ScriptEnv[k] != v {
+			t.Errorf("ScriptEnv[%q] = %q, want %q", k, cfg.ScriptEnv[k], v)
+		}
+	}
+}
+
+func TestLoad_WithScripts(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "igloo.ini")
+
+	content := `[container]
+image = images:debian/trixie/cloud
+name = test-igloo
+
+[scripts]
+timeout = 10m
+retries = 2
+keep_going = true
+library = ~/team/igloo-scripts
+include = vscode, docker,
+`
+
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if cfg.Scripts.Timeout != 10*time.Minute {
+		t.Errorf("Scripts.Timeout = %v, want %v", cfg.Scripts.Timeout, 10*time.Minute)
+	}
+	if cfg.Scripts.Retries != 2 {
+		t.Errorf("Scripts.Retries = %d, want %d", cfg.Scripts.Retries, 2)
+	}
+	if !cfg.Scripts.KeepGoing {
+		t.Error("Scripts.KeepGoing = false, want true")
+	}
+	if cfg.Scripts.Library != "~/team/igloo-scripts" {
+		t.Errorf("Scripts.Library = %q, want %q", cfg.Scripts.Library, "~/team/igloo-scripts")
+	}
+	wantInclude := []string{"vscode", "docker"}
+	if len(cfg.Scripts.Include) != len(wantInclude) {
+		t.Fatalf("Scripts.Include = %v, want %v", cfg.Scripts.Include, wantInclude)
+	}
+	for i := range wantInclude {
+		if cfg.Scripts.Include[i] != wantInclude[i] {
+			t.Errorf("Scripts.Include[%d] = %q, want %q", i, cfg.Scripts.Include[i], wantInclude[i])
+		}
+	}
+}
+
+func TestLoad_ProjectPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"workspace", "workspace", false},
+		{"mirror", "mirror", false},
+		{"absolute", "/srv/src/app", false},
+		{"relative", "src/app", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "igloo.ini")
+			content := "[mounts]\nproject = true\nproject_path = " + tt.value + "\n"
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.Mounts.ProjectPath != tt.value {
+				t.Errorf("Mounts.ProjectPath = %q, want %q", cfg.Mounts.ProjectPath, tt.value)
+			}
+		})
+	}
+}
+
+func TestLoad_DBus(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		wantFiltered bool
+		wantErr      bool
+	}{
+		{"default", "", true, false},
+		{"filtered", "filtered", true, false},
+		{"full", "full", false, false},
+		{"unknown", "open", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "igloo.ini")
+			content := "[dbus]\nenabled = true\nmode = " + tt.mode + "\n"
+			if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write test config: %v", err)
+			}
+
+			cfg, err := Load(configPath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && cfg.DBus.Filtered() != tt.wantFiltered {
+				t.Errorf("DBus.Filtered() = %v, want %v", cfg.DBus.Filtered(), tt.wantFiltered)
+			}
+		})
+	}
+}
+
+func TestProjectMountPath(t *testing.T) {
+	tests := []struct {
+		projectPath string
+		want        string
+	}{
+		{"", "/home/dev/workspace/api"},
+		{"workspace", "/home/dev/workspace/api"},
+		{"mirror", "/home/dev/work/api"},
+		{"/srv/app/", "/srv/app"},
+	}
+
+	for _, tt := range tests {
+		m := MountsConfig{Project: true, ProjectPath: tt.projectPath}
+		if got := m.ProjectMountPath("dev", "/home/dev/work/api"); got != tt.want {
+			t.Errorf("ProjectMountPath() with project_path=%q = %q, want %q", tt.projectPath, got, tt.want)
+		}
+	}
+}
+
 func TestLoad_FileNotFound(t *testing.T) {
 	_, err := Load("/nonexistent/path/igloo.ini")
 	if err == nil {
@@ -193,6 +362,324 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWrite_Scripts(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Scripts: ScriptsConfig{
+			Timeout:   90 * time.Second,
+			Retries:   3,
+			KeepGoing: true,
+			Include:   []string{"vscode", "docker"},
+		},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+
+	if loaded.Scripts.Timeout != cfg.Scripts.Timeout {
+		t.Errorf("Scripts.Timeout = %v, want %v", loaded.Scripts.Timeout, cfg.Scripts.Timeout)
+	}
+	if loaded.Scripts.Retries != cfg.Scripts.Retries {
+		t.Errorf("Scripts.Retries = %d, want %d", loaded.Scripts.Retries, cfg.Scripts.Retries)
+	}
+	if loaded.Scripts.KeepGoing != cfg.Scripts.KeepGoing {
+		t.Errorf("Scripts.KeepGoing = %v, want %v", loaded.Scripts.KeepGoing, cfg.Scripts.KeepGoing)
+	}
+	if len(loaded.Scripts.Include) != len(cfg.Scripts.Include) {
+		t.Errorf("Scripts.Include = %v, want %v", loaded.Scripts.Include, cfg.Scripts.Include)
+	}
+}
+
+func TestWrite_ScriptEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		ScriptEnv: map[string]string{
+			"FOO": "bar",
+			"BAZ": "qux",
+		},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+
+	for k, v := range cfg.ScriptEnv {
+		if loaded.ScriptEnv[k] != v {
+			t.Errorf("ScriptEnv[%q] = %q, want %q", k, loaded.ScriptEnv[k], v)
+		}
+	}
+}
+
+func TestWrite_Forward(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Forward: ForwardConfig{SSHAgent: true, GPGAgent: true},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if loaded.Forward != cfg.Forward {
+		t.Errorf("Forward = %+v, want %+v", loaded.Forward, cfg.Forward)
+	}
+
+	// Nothing forwarded, no section
+	cfg.Forward = ForwardConfig{}
+	data, err := Render(cfg)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if strings.Contains(string(data), "[forward]") {
+		t.Errorf("Render() wrote an empty [forward] section:\n%s", data)
+	}
+}
+
+func TestWrite_Audio(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Audio: AudioConfig{Enabled: true},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if !loaded.Audio.Enabled {
+		t.Error("Audio.Enabled = false, want true")
+	}
+}
+
+func TestWrite_DBus(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		DBus: DBusConfig{Enabled: true, Mode: DBusFull},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if !loaded.DBus.Enabled || loaded.DBus.Mode != DBusFull {
+		t.Errorf("DBus = %+v, want enabled in full mode", loaded.DBus)
+	}
+}
+
+func TestWrite_Open(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Open: OpenConfig{Enabled: true, Schemes: []string{"https", "vscode"}},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if !loaded.Open.Enabled {
+		t.Error("Open.Enabled = false, want true")
+	}
+	if got := loaded.Open.AllowedSchemes(); !reflect.DeepEqual(got, []string{"https", "vscode"}) {
+		t.Errorf("Open.AllowedSchemes() = %v, want [https vscode]", got)
+	}
+	if got := (OpenConfig{}).AllowedSchemes(); !reflect.DeepEqual(got, DefaultOpenSchemes) {
+		t.Errorf("default AllowedSchemes() = %v, want %v", got, DefaultOpenSchemes)
+	}
+}
+
+func TestWrite_HostExec(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		HostExec: HostExecConfig{Allow: []string{"flatpak", "podman"}},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if !reflect.DeepEqual(loaded.HostExec.Allow, []string{"flatpak", "podman"}) {
+		t.Errorf("HostExec.Allow = %v, want [flatpak podman]", loaded.HostExec.Allow)
+	}
+}
+
+func TestLoad_Shell(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+	if err := os.WriteFile(configPath, []byte("[container]\nname = igloo-api\n"), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if cfg.Shell != (ShellConfig{}) {
+		t.Errorf("Shell without a [shell] section = %+v, want prompt and title shown", cfg.Shell)
+	}
+
+	content := "[shell]\nprompt = true\ntitle = false\nlabel = api\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+	cfg, err = Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if want := (ShellConfig{HideTitle: true, Label: "api"}); cfg.Shell != want {
+		t.Errorf("Shell = %+v, want %+v", cfg.Shell, want)
+	}
+}
+
+func TestWrite_Shell(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Shell: ShellConfig{HidePrompt: true, Label: "api"},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if loaded.Shell != cfg.Shell {
+		t.Errorf("Shell = %+v, want %+v", loaded.Shell, cfg.Shell)
+	}
+}
+
+func TestWrite_Shared(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+
+	cfg := &IglooConfig{
+		Container: ContainerConfig{
+			Image: "images:debian/trixie/cloud",
+			Name:  "my-igloo",
+		},
+		Shared: []string{".vscode", ".config/Code/User"},
+	}
+
+	if err := Write(configPath, cfg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	loaded, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed after Write(): %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Shared, cfg.Shared) {
+		t.Errorf("Shared = %v, want %v", loaded.Shared, cfg.Shared)
+	}
+}
+
+func TestLoad_InvalidShared(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+	content := "[shared_state]\npaths = .vscode, ../outside\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	if _, err := Load(configPath); err == nil {
+		t.Error("Load() with a path outside the home directory should fail")
+	}
+}
+
+func TestRender(t *testing.T) {
+	cfg := &IglooConfig{
+		Container: ContainerConfig{Image: "images:debian/trixie/cloud", Name: "igloo-api"},
+		Mounts:    MountsConfig{Home: true, Project: true},
+	}
+
+	data, err := Render(cfg)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	// Rendering must match what Write puts on disk
+	path := filepath.Join(t.TempDir(), "igloo.ini")
+	if err := Write(path, cfg); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(written) {
+		t.Errorf("Render() = %q, want %q", data, written)
+	}
+}
+
 func TestRemove(t *testing.T) {
 	tmpDir := t.TempDir()
 	configPath := filepath.Join(tmpDir, "igloo.ini")
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"slices"
+	"strings"
 )
 
 // SupportedDistros maps distribution names to their supported releases
@@ -48,3 +49,22 @@ func IsDistroSupported(distro string) bool {
 	_, ok := SupportedDistros[distro]
 	return ok
 }
+
+// ParseImage extracts the distro and release from an image reference
+// such as "images:debian/trixie/cloud". Empty strings are returned for
+// parts that cannot be determined.
+func ParseImage(image string) (distro, release string) {
+	// Strip the remote prefix (e.g., "images:")
+	if idx := strings.Index(image, ":"); idx >= 0 {
+		image = image[idx+1:]
+	}
+
+	parts := strings.Split(image, "/")
+	if len(parts) > 0 {
+		distro = parts[0]
+	}
+	if len(parts) > 1 {
+		release = parts[1]
+	}
+	return distro, release
+}
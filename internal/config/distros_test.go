@@ -124,3 +124,29 @@ func TestSupportedDistrosCompleteness(t *testing.T) {
 		}
 	}
 }
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image       string
+		wantDistro  string
+		wantRelease string
+	}{
+		{"images:debian/trixie/cloud", "debian", "trixie"},
+		{"images:ubuntu/questing", "ubuntu", "questing"},
+		{"fedora/43/cloud", "fedora", "43"},
+		{"images:archlinux", "archlinux", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			distro, release := ParseImage(tt.image)
+			if distro != tt.wantDistro {
+				t.Errorf("ParseImage(%q) distro = %q, want %q", tt.image, distro, tt.wantDistro)
+			}
+			if release != tt.wantRelease {
+				t.Errorf("ParseImage(%q) release = %q, want %q", tt.image, release, tt.wantRelease)
+			}
+		})
+	}
+}
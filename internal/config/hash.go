@@ -3,7 +3,10 @@ package config
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -20,17 +23,49 @@ func GetDataDir() string {
 	return filepath.Join(dataHome, "igloo")
 }
 
-// HashConfigDir computes a SHA256 hash of all files in the .igloo directory
-func HashConfigDir() (string, error) {
-	return hashDir(ConfigDir)
+// HashConfigDir computes a SHA256 hash of all files in the project's .igloo
+// directory, plus any library scripts listed in the [scripts] include key
+func HashConfigDir(projectDir string) (string, error) {
+	h := sha256.New()
+	if err := hashDirInto(h, filepath.Join(projectDir, ConfigDir)); err != nil {
+		return "", err
+	}
+
+	// Included library scripts live outside .igloo but still affect
+	// provisioning. A config that can't be read would leave them out, so
+	// that is an error rather than a partial hash.
+	cfg, err := Load(filepath.Join(projectDir, ConfigPath()))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if cfg != nil && len(cfg.Scripts.Include) > 0 {
+		scripts, err := ResolveIncludes(cfg.Scripts.Include, cfg.Scripts.Library)
+		if err != nil {
+			return "", err
+		}
+		for _, s := range scripts {
+			if err := hashFileInto(h, "lib:"+s.Name, s.Path); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // hashDir computes a SHA256 hash of all files in a directory
 func hashDir(dir string) (string, error) {
 	h := sha256.New()
+	if err := hashDirInto(h, dir); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
+// hashDirInto writes the structure and file contents of a directory to h
+func hashDirInto(h hash.Hash, dir string) error {
 	// Walk the config directory and hash all file contents
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -48,33 +83,28 @@ func hashDir(dir string) (string, error) {
 		}
 
 		// Hash the relative path and file contents
-		h.Write([]byte("file:" + relPath + "\n"))
+		return hashFileInto(h, "file:"+relPath, path)
+	})
+}
 
-		// Read and hash file contents
-		f, err := os.Open(path)
-		if err != nil {
-			return err
-		}
+// hashFileInto writes a label followed by the contents of a file to h
+func hashFileInto(h hash.Hash, label, path string) error {
+	h.Write([]byte(label + "\n"))
 
-		if _, err := io.Copy(h, f); err != nil {
-			if closeErr := f.Close(); closeErr != nil {
-				return closeErr
-			}
-			return err
-		}
+	// Read and hash file contents
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
 
-		if err := f.Close(); err != nil {
-			return err
+	if _, err := io.Copy(h, f); err != nil {
+		if closeErr := f.Close(); closeErr != nil {
+			return closeErr
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return "", err
+		return err
 	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return f.Close()
 }
 
 // GetStoredHash retrieves the stored hash for a container
@@ -113,8 +143,8 @@ func RemoveStoredHash(containerName string) error {
 
 // ConfigChanged checks if the .igloo directory has changed since last provision
 // Returns (changed, currentHash, error)
-func ConfigChanged(containerName string) (bool, string, error) {
-	currentHash, err := HashConfigDir()
+func ConfigChanged(projectDir, containerName string) (bool, string, error) {
+	currentHash, err := HashConfigDir(projectDir)
 	if err != nil {
 		return false, "", err
 	}
@@ -99,10 +99,108 @@ func TestStoreAndGetHash(t *testing.T) {
 	}
 }
 
+func TestHashConfigDir_IncludesLibraryScripts(t *testing.T) {
+	projectDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := os.MkdirAll(filepath.Join(projectDir, ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "[container]\nname = test\n\n[scripts]\ninclude = vscode\n"
+	if err := os.WriteFile(filepath.Join(projectDir, ConfigPath()), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing library script is an error
+	if _, err := HashConfigDir(projectDir); err == nil {
+		t.Error("HashConfigDir() should fail when an included script is missing")
+	}
+
+	if err := os.MkdirAll(UserScriptsPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	libScript := filepath.Join(UserScriptsPath(), "vscode.sh")
+	if err := os.WriteFile(libScript, []byte("echo one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash1, err := HashConfigDir(projectDir)
+	if err != nil {
+		t.Fatalf("HashConfigDir() error = %v", err)
+	}
+
+	// Changing the library script should change the hash
+	if err := os.WriteFile(libScript, []byte("echo two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := HashConfigDir(projectDir)
+	if err != nil {
+		t.Fatalf("HashConfigDir() error = %v", err)
+	}
+	if hash1 == hash2 {
+		t.Error("HashConfigDir() should change when an included library script changes")
+	}
+}
+
+func TestHashConfigDir_InvalidConfig(t *testing.T) {
+	projectDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectDir, ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "[mounts]\nproject_path = relative/path\n"
+	if err := os.WriteFile(filepath.Join(projectDir, ConfigPath()), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := HashConfigDir(projectDir); err == nil {
+		t.Error("HashConfigDir() should fail when the config can't be loaded")
+	}
+}
+
 func TestConfigChanged(t *testing.T) {
-	// This test requires actual .igloo directory, so we'll skip the ConfigChanged test
-	// and focus on testing the underlying hashDir and store/get functions
-	t.Skip("ConfigChanged requires modifying package-level constants")
+	projectDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := os.MkdirAll(filepath.Join(projectDir, ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(projectDir, ConfigPath())
+	if err := os.WriteFile(configPath, []byte("[container]\nname = test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// No stored hash yet - not "changed"
+	changed, currentHash, err := ConfigChanged(projectDir, "test")
+	if err != nil {
+		t.Fatalf("ConfigChanged() error = %v", err)
+	}
+	if changed {
+		t.Error("ConfigChanged() = true with no stored hash, want false")
+	}
+	if err := StoreHash("test", currentHash); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged config
+	changed, _, err = ConfigChanged(projectDir, "test")
+	if err != nil {
+		t.Fatalf("ConfigChanged() error = %v", err)
+	}
+	if changed {
+		t.Error("ConfigChanged() = true for unchanged config, want false")
+	}
+
+	// Modified config
+	if err := os.WriteFile(configPath, []byte("[container]\nname = changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, _, err = ConfigChanged(projectDir, "test")
+	if err != nil {
+		t.Fatalf("ConfigChanged() error = %v", err)
+	}
+	if !changed {
+		t.Error("ConfigChanged() = false for modified config, want true")
+	}
 }
 
 func TestGetDataDir(t *testing.T) {
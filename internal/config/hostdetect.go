@@ -13,24 +13,10 @@ func DetectHostOS() (distro, release string) {
 	defaultDistro := "ubuntu"
 	defaultRelease := "questing"
 
-	file, err := os.Open("/etc/os-release")
+	osInfo, err := readOSRelease()
 	if err != nil {
 		return defaultDistro, defaultRelease
 	}
-	defer func() { _ = file.Close() }()
-
-	osInfo := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := parts[0]
-			value := strings.Trim(parts[1], "\"")
-			osInfo[key] = value
-		}
-	}
 
 	// Get distribution ID
 	distro = strings.ToLower(osInfo["ID"])
@@ -59,3 +45,36 @@ func DetectHostOS() (distro, release string) {
 
 	return distro, release
 }
+
+// HostOSID returns the distribution ID from /etc/os-release, even if igloo
+// doesn't support it, or "" if it can't be read
+func HostOSID() string {
+	osInfo, err := readOSRelease()
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(osInfo["ID"])
+}
+
+// readOSRelease parses /etc/os-release into a map of its fields
+func readOSRelease() (map[string]string, error) {
+	file, err := os.Open("/etc/os-release")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	osInfo := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			key := parts[0]
+			value := strings.Trim(parts[1], "\"")
+			osInfo[key] = value
+		}
+	}
+	return osInfo, scanner.Err()
+}
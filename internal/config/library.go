@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GetConfigHome returns the XDG config directory for igloo
+// Uses $XDG_CONFIG_HOME/igloo or ~/.config/igloo
+func GetConfigHome() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home := os.Getenv("HOME")
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "igloo")
+}
+
+// UserScriptsPath returns the path to the user's shared script library
+func UserScriptsPath() string {
+	return filepath.Join(GetConfigHome(), ScriptsDir)
+}
+
+// LibraryDirs returns the directories searched for included scripts, in
+// priority order: the user library first, then the team library if set
+func LibraryDirs(teamLibrary string) []string {
+	dirs := []string{UserScriptsPath()}
+	if teamLibrary != "" {
+		dirs = append(dirs, expandHome(teamLibrary))
+	}
+	return dirs
+}
+
+// LibraryScript is a script resolved from a shared library
+type LibraryScript struct {
+	Name string // File name of the script
+	Path string // Absolute path on the host
+}
+
+// ResolveIncludes locates each included script in the library directories.
+// An entry matches a file with the same name, or the same name plus ".sh".
+func ResolveIncludes(include []string, teamLibrary string) ([]LibraryScript, error) {
+	dirs := LibraryDirs(teamLibrary)
+
+	var scripts []LibraryScript
+	for _, entry := range include {
+		script, ok := findLibraryScript(entry, dirs)
+		if !ok {
+			return nil, fmt.Errorf("included script %q not found in library (searched: %s)", entry, strings.Join(dirs, ", "))
+		}
+		scripts = append(scripts, script)
+	}
+
+	return scripts, nil
+}
+
+// findLibraryScript searches the library directories for a single entry
+func findLibraryScript(entry string, dirs []string) (LibraryScript, bool) {
+	// Entries are plain names, never paths
+	if entry != filepath.Base(entry) {
+		return LibraryScript{}, false
+	}
+
+	for _, dir := range dirs {
+		for _, name := range []string{entry, entry + ".sh"} {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return LibraryScript{Name: name, Path: path}, true
+			}
+		}
+	}
+	return LibraryScript{}, false
+}
+
+// expandHome replaces a leading ~/ with the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[2:])
+	}
+	return path
+}
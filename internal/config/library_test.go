@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetConfigHome(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/custom/config")
+
+	if got := GetConfigHome(); got != "/custom/config/igloo" {
+		t.Errorf("GetConfigHome() = %q, want %q", got, "/custom/config/igloo")
+	}
+	if got := UserScriptsPath(); got != "/custom/config/igloo/scripts" {
+		t.Errorf("UserScriptsPath() = %q, want %q", got, "/custom/config/igloo/scripts")
+	}
+}
+
+func TestGetConfigHome_Default(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/tester")
+
+	if got := GetConfigHome(); got != "/home/tester/.config/igloo" {
+		t.Errorf("GetConfigHome() = %q, want %q", got, "/home/tester/.config/igloo")
+	}
+}
+
+func TestLibraryDirs(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/cfg")
+	t.Setenv("HOME", "/home/tester")
+
+	dirs := LibraryDirs("")
+	if len(dirs) != 1 || dirs[0] != "/cfg/igloo/scripts" {
+		t.Errorf("LibraryDirs(\"\") = %v, want [/cfg/igloo/scripts]", dirs)
+	}
+
+	dirs = LibraryDirs("~/team/scripts")
+	want := []string{"/cfg/igloo/scripts", "/home/tester/team/scripts"}
+	if len(dirs) != len(want) {
+		t.Fatalf("LibraryDirs() = %v, want %v", dirs, want)
+	}
+	for i := range want {
+		if dirs[i] != want[i] {
+			t.Errorf("LibraryDirs()[%d] = %q, want %q", i, dirs[i], want[i])
+		}
+	}
+}
+
+func TestResolveIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	userLib := filepath.Join(tmpDir, "igloo", "scripts")
+	teamLib := filepath.Join(tmpDir, "team")
+	for _, dir := range []string{userLib, teamLib} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(userLib, "vscode.sh"):    "user vscode",
+		filepath.Join(userLib, "50-git"):       "user git",
+		filepath.Join(teamLib, "vscode.sh"):    "team vscode",
+		filepath.Join(teamLib, "docker.sh"):    "team docker",
+		filepath.Join(teamLib, "notascript.d"): "",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scripts, err := ResolveIncludes([]string{"vscode", "docker", "50-git"}, teamLib)
+	if err != nil {
+		t.Fatalf("ResolveIncludes() error = %v", err)
+	}
+
+	want := []LibraryScript{
+		{Name: "vscode.sh", Path: filepath.Join(userLib, "vscode.sh")}, // user library wins
+		{Name: "docker.sh", Path: filepath.Join(teamLib, "docker.sh")},
+		{Name: "50-git", Path: filepath.Join(userLib, "50-git")},
+	}
+	if len(scripts) != len(want) {
+		t.Fatalf("ResolveIncludes() = %v, want %v", scripts, want)
+	}
+	for i := range want {
+		if scripts[i] != want[i] {
+			t.Errorf("ResolveIncludes()[%d] = %+v, want %+v", i, scripts[i], want[i])
+		}
+	}
+}
+
+func TestResolveIncludes_Missing(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if _, err := ResolveIncludes([]string{"nope"}, ""); err == nil {
+		t.Error("ResolveIncludes() should fail for a missing script")
+	}
+}
+
+func TestResolveIncludes_RejectsPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "secret.sh"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ResolveIncludes([]string{"../secret"}, ""); err == nil {
+		t.Error("ResolveIncludes() should reject entries containing paths")
+	}
+}
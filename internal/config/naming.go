@@ -0,0 +1,24 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+)
+
+// ContainerPrefix is prepended to the project directory name to form the
+// default container name
+const ContainerPrefix = "igloo-"
+
+// DefaultContainerName returns the default container name for a project
+func DefaultContainerName(projectDir string) string {
+	return ContainerPrefix + filepath.Base(projectDir)
+}
+
+// UniqueContainerName returns a container name for a project that is stable
+// for the same project path and user, but distinct from projects in other
+// directories or belonging to other users that share the same base name
+func UniqueContainerName(projectDir, username string) string {
+	sum := sha256.Sum256([]byte(username + ":" + projectDir))
+	return DefaultContainerName(projectDir) + "-" + hex.EncodeToString(sum[:])[:6]
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultContainerName(t *testing.T) {
+	if got := DefaultContainerName("/home/dev/work/api"); got != "igloo-api" {
+		t.Errorf("DefaultContainerName() = %q, want %q", got, "igloo-api")
+	}
+}
+
+func TestUniqueContainerName(t *testing.T) {
+	work := UniqueContainerName("/home/dev/work/api", "dev")
+	oss := UniqueContainerName("/home/dev/oss/api", "dev")
+	other := UniqueContainerName("/home/dev/work/api", "alice")
+
+	if !strings.HasPrefix(work, "igloo-api-") {
+		t.Errorf("UniqueContainerName() = %q, want igloo-api- prefix", work)
+	}
+	if work != UniqueContainerName("/home/dev/work/api", "dev") {
+		t.Error("UniqueContainerName() should be stable for the same project and user")
+	}
+	if work == oss {
+		t.Errorf("projects in different directories share name %q", work)
+	}
+	if work == other {
+		t.Errorf("different users share name %q", work)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoProject is returned when no .igloo directory is found
+var ErrNoProject = errors.New("no .igloo directory found in this directory or any parent")
+
+// FindProjectRoot searches dir and its parents for an .igloo/igloo.ini file,
+// the same way git looks for .git, and returns the directory containing it
+func FindProjectRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ConfigPath())); err == nil && !info.IsDir() {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNoProject
+		}
+		dir = parent
+	}
+}
+
+// ProjectSubdir returns the path of dir relative to the project root, or ""
+// if dir is the root itself or lies outside it
+func ProjectSubdir(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return rel
+}
+
+// PathMapping pairs a host directory with where it is mounted in the container
+type PathMapping struct {
+	Host      string
+	Container string
+}
+
+// Mappings returns the host directories mounted into the container by the
+// [mounts] settings
+func (m MountsConfig) Mappings(username, hostHome, projectDir string) []PathMapping {
+	var mappings []PathMapping
+	if m.Project {
+		mappings = append(mappings, PathMapping{Host: projectDir, Container: m.ProjectMountPath(username, projectDir)})
+	}
+	if m.Home {
+		mappings = append(mappings, PathMapping{Host: hostHome, Container: filepath.Join("/home", username, "host")})
+	}
+	return mappings
+}
+
+// HostPath translates an absolute container path to the host path it is
+// mounted from, using the most specific mapping. It reports false if the path
+// isn't under any mapped directory.
+func HostPath(mappings []PathMapping, path string) (string, bool) {
+	path = filepath.Clean(path)
+	best := -1
+	var rel string
+	for i, m := range mappings {
+		r, err := filepath.Rel(m.Container, path)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best < 0 || len(m.Container) > len(mappings[best].Container) {
+			best, rel = i, r
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return filepath.Join(mappings[best].Host, rel), true
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ConfigPath()), []byte("[container]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nested := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{root, filepath.Join(root, "src"), nested} {
+		got, err := FindProjectRoot(dir)
+		if err != nil {
+			t.Fatalf("FindProjectRoot(%q) error = %v", dir, err)
+		}
+		if got != root {
+			t.Errorf("FindProjectRoot(%q) = %q, want %q", dir, got, root)
+		}
+	}
+}
+
+func TestFindProjectRoot_IgnoresDirWithoutConfig(t *testing.T) {
+	root := t.TempDir()
+
+	// An .igloo directory without igloo.ini doesn't count
+	if err := os.MkdirAll(filepath.Join(root, ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FindProjectRoot(root); !errors.Is(err, ErrNoProject) {
+		t.Errorf("FindProjectRoot() error = %v, want ErrNoProject", err)
+	}
+}
+
+func TestProjectSubdir(t *testing.T) {
+	tests := []struct {
+		root string
+		dir  string
+		want string
+	}{
+		{"/work/api", "/work/api", ""},
+		{"/work/api", "/work/api/src/pkg", "src/pkg"},
+		{"/work/api", "/work", ""},
+		{"/work/api", "/work/apiary", ""},
+		{"/work/api", "/work/api/..data", "..data"},
+	}
+
+	for _, tt := range tests {
+		if got := ProjectSubdir(tt.root, tt.dir); got != tt.want {
+			t.Errorf("ProjectSubdir(%q, %q) = %q, want %q", tt.root, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestHostPath(t *testing.T) {
+	m := MountsConfig{Home: true, Project: true}
+	mappings := m.Mappings("dev", "/home/dev", "/home/dev/work/api")
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"/home/dev/workspace/api", "/home/dev/work/api", true},
+		{"/home/dev/workspace/api/docs/index.html", "/home/dev/work/api/docs/index.html", true},
+		{"/home/dev/host/Downloads/report.pdf", "/home/dev/Downloads/report.pdf", true},
+		{"/home/dev/host", "/home/dev", true},
+		{"/home/dev/workspace/apiary", "", false},
+		{"/home/dev/.bashrc", "", false},
+		{"/etc/hosts", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := HostPath(mappings, tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("HostPath(%q) = (%q, %v), want (%q, %v)", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHostPath_MostSpecific(t *testing.T) {
+	// A mirrored project mount lies inside the home directory's mount point
+	mappings := []PathMapping{
+		{Host: "/home/dev", Container: "/home/dev"},
+		{Host: "/srv/api", Container: "/home/dev/api"},
+	}
+	if got, _ := HostPath(mappings, "/home/dev/api/main.go"); got != "/srv/api/main.go" {
+		t.Errorf("HostPath() = %q, want %q", got, "/srv/api/main.go")
+	}
+}
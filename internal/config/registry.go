@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RegistryEntry records which project an igloo container belongs to
+type RegistryEntry struct {
+	Name        string    `json:"name"`
+	ProjectPath string    `json:"project_path"`
+	Image       string    `json:"image"`
+	Created     time.Time `json:"created,omitzero"`
+	LastEntered time.Time `json:"last_entered,omitzero"`
+	Exports     []Export  `json:"exports,omitempty"`
+
+	// Devices of a rebuilt container still to be added to its replacement,
+	// keyed by device name. They are kept here until provisioning finishes,
+	// since the old container is already gone.
+	RestoreDevices map[string]map[string]string `json:"restore_devices,omitempty"`
+}
+
+// Kinds of Export
+const (
+	// ExportApp is a desktop application exported to the host's app menu
+	ExportApp = "app"
+	// ExportBin is a command exported to the host's ~/.local/bin
+	ExportBin = "bin"
+)
+
+// Export records an app or command exported from a container to the host,
+// so it can be removed again when the container goes away
+type Export struct {
+	Kind  string   `json:"kind"`
+	Name  string   `json:"name"`
+	Files []string `json:"files"` // Host files written for the export
+}
+
+// AddExport records an export, replacing any earlier export of the same
+// kind and name
+func (e *RegistryEntry) AddExport(export Export) {
+	e.RemoveExport(export.Kind, export.Name)
+	e.Exports = append(e.Exports, export)
+}
+
+// RemoveExport forgets an export and returns it, or nil if there was none
+func (e *RegistryEntry) RemoveExport(kind, name string) *Export {
+	for i, export := range e.Exports {
+		if export.Kind == kind && export.Name == name {
+			e.Exports = append(e.Exports[:i], e.Exports[i+1:]...)
+			return &export
+		}
+	}
+	return nil
+}
+
+// registryFile returns the path of the registry entry for a container
+func registryFile(containerName string) string {
+	return filepath.Join(GetDataDir(), containerName+".json")
+}
+
+// GetRegistryEntry retrieves the registry entry for a container.
+// Returns nil if the container is not registered.
+func GetRegistryEntry(containerName string) (*RegistryEntry, error) {
+	data, err := os.ReadFile(registryFile(containerName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // Not registered
+		}
+		return nil, err
+	}
+
+	var entry RegistryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveRegistryEntry writes the registry entry for a container
+func SaveRegistryEntry(entry *RegistryEntry) error {
+	dataDir := GetDataDir()
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(registryFile(entry.Name), data, 0644)
+}
+
+// RegisterContainer records a newly provisioned container. Re-registering a
+// container, after a rebuild or resumed provision, keeps its creation time
+// and exports, whose host files are still in place; devices waiting to be
+// restored have been by then.
+func RegisterContainer(containerName, projectPath, image string) error {
+	entry, err := GetRegistryEntry(containerName)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &RegistryEntry{Name: containerName}
+	}
+	if entry.Created.IsZero() {
+		entry.Created = time.Now()
+	}
+
+	entry.ProjectPath = projectPath
+	entry.Image = image
+	entry.RestoreDevices = nil
+	return SaveRegistryEntry(entry)
+}
+
+// RecordEnter updates the last entered time for a container, registering it
+// first if it was provisioned before the registry existed
+func RecordEnter(containerName, projectPath, image string) error {
+	entry, err := GetRegistryEntry(containerName)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &RegistryEntry{Name: containerName}
+	}
+
+	entry.ProjectPath = projectPath
+	entry.Image = image
+	entry.LastEntered = time.Now()
+	return SaveRegistryEntry(entry)
+}
+
+// RemoveRegistryEntry deletes the registry entry for a container
+func RemoveRegistryEntry(containerName string) error {
+	err := os.Remove(registryFile(containerName))
+	if os.IsNotExist(err) {
+		return nil // Already gone
+	}
+	return err
+}
+
+// ListRegistry returns all registered containers, sorted by name
+func ListRegistry() ([]RegistryEntry, error) {
+	dataDir := GetDataDir()
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var registry []RegistryEntry
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := entry.Name()
+		reg, err := GetRegistryEntry(name[:len(name)-5]) // Remove .json suffix
+		if err != nil {
+			return nil, err
+		}
+		if reg != nil {
+			registry = append(registry, *reg)
+		}
+	}
+
+	sort.Slice(registry, func(i, j int) bool {
+		return registry[i].Name < registry[j].Name
+	})
+	return registry, nil
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry_RegisterAndGet(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Initially not registered
+	entry, err := GetRegistryEntry("igloo-api")
+	if err != nil {
+		t.Fatalf("GetRegistryEntry() error = %v", err)
+	}
+	if entry != nil {
+		t.Errorf("GetRegistryEntry() = %+v, want nil", entry)
+	}
+
+	if err := RegisterContainer("igloo-api", "/home/dev/work/api", "images:debian/trixie/cloud"); err != nil {
+		t.Fatalf("RegisterContainer() error = %v", err)
+	}
+
+	entry, err = GetRegistryEntry("igloo-api")
+	if err != nil {
+		t.Fatalf("GetRegistryEntry() error = %v", err)
+	}
+	if entry == nil {
+		t.Fatal("GetRegistryEntry() = nil, want entry")
+	}
+	if entry.ProjectPath != "/home/dev/work/api" {
+		t.Errorf("ProjectPath = %q, want %q", entry.ProjectPath, "/home/dev/work/api")
+	}
+	if entry.Image != "images:debian/trixie/cloud" {
+		t.Errorf("Image = %q, want %q", entry.Image, "images:debian/trixie/cloud")
+	}
+	if entry.Created.IsZero() {
+		t.Error("Created should be set")
+	}
+	if !entry.LastEntered.IsZero() {
+		t.Error("LastEntered should not be set before entering")
+	}
+}
+
+func TestRegistry_RecordEnter(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	// Recording an enter for an unregistered container registers it
+	if err := RecordEnter("igloo-web", "/srv/web", "images:ubuntu/noble/cloud"); err != nil {
+		t.Fatalf("RecordEnter() error = %v", err)
+	}
+
+	entry, err := GetRegistryEntry("igloo-web")
+	if err != nil || entry == nil {
+		t.Fatalf("GetRegistryEntry() = %v, %v", entry, err)
+	}
+	if entry.LastEntered.IsZero() {
+		t.Error("LastEntered should be set after RecordEnter()")
+	}
+	if entry.ProjectPath != "/srv/web" {
+		t.Errorf("ProjectPath = %q, want %q", entry.ProjectPath, "/srv/web")
+	}
+}
+
+func TestRegistry_RegisterAgain(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := RegisterContainer("igloo-api", "/home/dev/work/api", "images:debian/trixie/cloud"); err != nil {
+		t.Fatalf("RegisterContainer() error = %v", err)
+	}
+	entry, err := GetRegistryEntry("igloo-api")
+	if err != nil || entry == nil {
+		t.Fatalf("GetRegistryEntry() = %v, %v", entry, err)
+	}
+	created := entry.Created
+	entry.AddExport(Export{Kind: ExportBin, Name: "code", Files: []string{"/b/code"}})
+	entry.RestoreDevices = map[string]map[string]string{
+		"web": {"type": "proxy", "listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:8080"},
+	}
+	if err := SaveRegistryEntry(entry); err != nil {
+		t.Fatalf("SaveRegistryEntry() error = %v", err)
+	}
+
+	// A rebuild registers the container again, with the new image
+	if err := RegisterContainer("igloo-api", "/home/dev/work/api", "images:debian/forky/cloud"); err != nil {
+		t.Fatalf("RegisterContainer() error = %v", err)
+	}
+	entry, err = GetRegistryEntry("igloo-api")
+	if err != nil || entry == nil {
+		t.Fatalf("GetRegistryEntry() = %v, %v", entry, err)
+	}
+	if entry.Image != "images:debian/forky/cloud" {
+		t.Errorf("Image = %q, want %q", entry.Image, "images:debian/forky/cloud")
+	}
+	if !entry.Created.Equal(created) {
+		t.Errorf("Created = %v, want it kept at %v", entry.Created, created)
+	}
+	if len(entry.Exports) != 1 || entry.Exports[0].Name != "code" {
+		t.Errorf("Exports = %+v, want the code export kept", entry.Exports)
+	}
+	if entry.RestoreDevices != nil {
+		t.Errorf("RestoreDevices = %+v, want them cleared once provisioned", entry.RestoreDevices)
+	}
+}
+
+func TestRegistry_ListAndRemove(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	for _, name := range []string{"igloo-b", "igloo-a"} {
+		if err := RegisterContainer(name, "/projects/"+name, "images:debian/trixie/cloud"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Hash files share the data directory and must be ignored
+	if err := StoreHash("igloo-c", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ListRegistry()
+	if err != nil {
+		t.Fatalf("ListRegistry() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "igloo-a" || entries[1].Name != "igloo-b" {
+		t.Errorf("ListRegistry() = %+v, want igloo-a, igloo-b", entries)
+	}
+
+	if err := RemoveRegistryEntry("igloo-a"); err != nil {
+		t.Fatalf("RemoveRegistryEntry() error = %v", err)
+	}
+	if err := RemoveRegistryEntry("igloo-a"); err != nil {
+		t.Errorf("RemoveRegistryEntry() on missing entry error = %v, want nil", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataHome, "igloo", "igloo-a.json")); !os.IsNotExist(err) {
+		t.Error("registry file should not exist after RemoveRegistryEntry()")
+	}
+}
+
+func TestListRegistry_NoDataDir(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", filepath.Join(t.TempDir(), "missing"))
+
+	entries, err := ListRegistry()
+	if err != nil {
+		t.Errorf("ListRegistry() error = %v, want nil", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ListRegistry() = %v, want empty", entries)
+	}
+}
+
+func TestRegistry_Exports(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	entry := &RegistryEntry{Name: "igloo-api", ProjectPath: "/home/dev/work/api"}
+	entry.AddExport(Export{Kind: ExportApp, Name: "code", Files: []string{"/a/code.desktop"}})
+	entry.AddExport(Export{Kind: ExportBin, Name: "code", Files: []string{"/b/code"}})
+	// Exporting again replaces the earlier export
+	entry.AddExport(Export{Kind: ExportApp, Name: "code", Files: []string{"/a/code.desktop", "/a/code.png"}})
+
+	if err := SaveRegistryEntry(entry); err != nil {
+		t.Fatalf("SaveRegistryEntry() error = %v", err)
+	}
+	loaded, err := GetRegistryEntry("igloo-api")
+	if err != nil || loaded == nil {
+		t.Fatalf("GetRegistryEntry() = %v, %v", loaded, err)
+	}
+	if len(loaded.Exports) != 2 {
+		t.Fatalf("got %d exports, want 2: %+v", len(loaded.Exports), loaded.Exports)
+	}
+
+	removed := loaded.RemoveExport(ExportApp, "code")
+	if removed == nil || len(removed.Files) != 2 {
+		t.Errorf("RemoveExport() = %+v, want the app export with 2 files", removed)
+	}
+	if loaded.RemoveExport(ExportApp, "code") != nil {
+		t.Error("RemoveExport() of a removed export should return nil")
+	}
+	if len(loaded.Exports) != 1 || loaded.Exports[0].Kind != ExportBin {
+		t.Errorf("Exports = %+v, want only the bin export", loaded.Exports)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// SharedStateDir returns the host directory holding state shared by every
+// igloo, such as editor settings: $XDG_CONFIG_HOME/igloo/shared_state
+func SharedStateDir() string {
+	return filepath.Join(GetConfigHome(), "shared_state")
+}
+
+// SharedStatePath returns where a shared directory is kept on the host
+func SharedStatePath(path string) string {
+	return filepath.Join(SharedStateDir(), path)
+}
+
+// CleanSharedPath normalizes a shared state path to one relative to the
+// container user's home directory. A leading ~/ is accepted; absolute paths
+// and paths leaving the home directory are not.
+func CleanSharedPath(path string) (string, error) {
+	clean := filepath.Clean(strings.TrimPrefix(path, "~/"))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid shared state path %q: must be relative to your home directory", path)
+	}
+	return clean, nil
+}
+
+// EnsureSharedState creates a shared directory on the host if it doesn't
+// exist yet, so it can be linked into containers
+func EnsureSharedState(path string) error {
+	return os.MkdirAll(SharedStatePath(path), 0755)
+}
+
+// SaveSharedState replaces the [shared_state] paths in an igloo.ini file,
+// leaving the rest of the file as it is
+func SaveSharedState(configPath string, paths []string) error {
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config file: %w", err)
+	}
+
+	if len(paths) == 0 {
+		cfg.DeleteSection("shared_state")
+	} else {
+		sec := cfg.Section("shared_state")
+		if sec.Comment == "" {
+			sec.Comment = sharedStateComment
+		}
+		sec.Key("paths").SetValue(strings.Join(paths, ", "))
+	}
+	return cfg.SaveTo(configPath)
+}
+
+// sharedStateComment describes the [shared_state] section in igloo.ini
+const sharedStateComment = "Directories under ~/ shared by every igloo, kept in ~/.config/igloo/shared_state"
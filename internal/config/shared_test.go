@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanSharedPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: ".vscode", want: ".vscode"},
+		{path: "~/.vscode/", want: ".vscode"},
+		{path: ".config/Code/../Code/User", want: ".config/Code/User"},
+		{path: "/etc", wantErr: true},
+		{path: "../other", wantErr: true},
+		{path: "~/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := CleanSharedPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CleanSharedPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CleanSharedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSharedStatePath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/cfg")
+
+	if got, want := SharedStatePath(".config/Code"), "/cfg/igloo/shared_state/.config/Code"; got != want {
+		t.Errorf("SharedStatePath() = %q, want %q", got, want)
+	}
+}
+
+func TestSaveSharedState(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "igloo.ini")
+	content := `[container]
+image = images:debian/trixie
+; Keep this name
+name = test-igloo
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test config: %v", err)
+	}
+
+	if err := SaveSharedState(configPath, []string{".vscode", ".config/Code"}); err != nil {
+		t.Fatalf("SaveSharedState() error: %v", err)
+	}
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if want := []string{".vscode", ".config/Code"}; !reflect.DeepEqual(cfg.Shared, want) {
+		t.Errorf("Shared = %v, want %v", cfg.Shared, want)
+	}
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), "; Keep this name") {
+		t.Errorf("SaveSharedState() dropped existing comments:\n%s", data)
+	}
+
+	// Removing the last path drops the section
+	if err := SaveSharedState(configPath, nil); err != nil {
+		t.Fatalf("SaveSharedState(nil) error: %v", err)
+	}
+	data, _ = os.ReadFile(configPath)
+	if strings.Contains(string(data), "shared_state") {
+		t.Errorf("SaveSharedState(nil) left the section behind:\n%s", data)
+	}
+}
@@ -0,0 +1,176 @@
+// Package dbus passes the host's D-Bus session bus through to igloo
+// containers, optionally filtered down to the desktop portal, notification
+// and secret service interfaces.
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// Device is the name of the proxy device that forwards the session bus
+const Device = "dbus"
+
+// envKey is the instance config key that points clients at the bus
+const envKey = "environment.DBUS_SESSION_BUS_ADDRESS"
+
+// FilterRules are the bus names a filtered bus may talk to: file pickers and
+// URL opening go through the desktop portal, plus notifications and the
+// secret service keyring
+var FilterRules = []string{
+	"org.freedesktop.portal.*",
+	"org.freedesktop.Notifications",
+	"org.freedesktop.secrets",
+}
+
+// ErrNoSessionBus is returned when the host has no session bus
+var ErrNoSessionBus = errors.New("no D-Bus session bus on the host (DBUS_SESSION_BUS_ADDRESS is not set)")
+
+// ContainerSocket returns where the bus socket appears in the container.
+// It stays clear of /run/user/<uid>/bus, where an image that starts a
+// systemd user session binds its own bus; DBUS_SESSION_BUS_ADDRESS points
+// clients here instead.
+func ContainerSocket(uid int) string {
+	return hostsock.ContainerPath(uid, "igloo-bus")
+}
+
+// ParseAddress returns the socket path of the first unix address in a D-Bus
+// address list, such as "unix:path=/run/user/1000/bus". Abstract sockets are
+// reported with abstract set, since they can't be forwarded as a file.
+func ParseAddress(addr string) (path string, abstract bool, err error) {
+	for _, a := range strings.Split(addr, ";") {
+		transport, params, ok := strings.Cut(a, ":")
+		if !ok || transport != "unix" {
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			switch key {
+			case "path":
+				return unescape(value), false, nil
+			case "abstract":
+				return unescape(value), true, nil
+			}
+		}
+	}
+	return "", false, fmt.Errorf("no unix socket in D-Bus address %q", addr)
+}
+
+// unescape decodes the %xx escapes allowed in D-Bus address values
+func unescape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) {
+			var c byte
+			if _, err := fmt.Sscanf(value[i+1:i+3], "%02x", &c); err == nil {
+				b.WriteByte(c)
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// ProxyArgs returns the xdg-dbus-proxy arguments that serve a filtered view
+// of the bus at busAddr on the socket listen
+func ProxyArgs(busAddr, listen string) []string {
+	args := []string{busAddr, listen, "--filter"}
+	for _, name := range FilterRules {
+		args = append(args, "--talk="+name)
+	}
+	return args
+}
+
+// Configure points the container's session bus at the host's current bus,
+// through a filtering proxy if filtered is set. The host bus changes
+// between login sessions, so this is re-checked on every enter. If the host
+// has no bus, the stale device is removed and the reason returned.
+func Configure(client *incus.Client, name string, filtered bool) error {
+	hostSock, err := hostSocket(name, filtered)
+	if err != nil {
+		if removeErr := hostsock.Remove(client, name, Device, envKey); removeErr != nil {
+			return removeErr
+		}
+		return err
+	}
+
+	containerSock := ContainerSocket(os.Getuid())
+	return hostsock.Forward(client, name, Device, hostSock, containerSock, envKey, "unix:path="+containerSock)
+}
+
+// hostSocket returns the host socket to forward: the session bus itself, or
+// a filtering proxy in front of it
+func hostSocket(name string, filtered bool) (string, error) {
+	addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if addr == "" {
+		return "", ErrNoSessionBus
+	}
+
+	if !filtered {
+		path, abstract, err := ParseAddress(addr)
+		if err != nil {
+			return "", err
+		}
+		if abstract {
+			return "", fmt.Errorf("the session bus uses an abstract socket, which can only be forwarded with mode = filtered")
+		}
+		return path, nil
+	}
+
+	sock, err := hostsock.HostPath(name, Device)
+	if err != nil {
+		return "", fmt.Errorf("nowhere to put the filtering proxy: %w", err)
+	}
+	if err := startFilterProxy(addr, sock); err != nil {
+		return "", err
+	}
+	return sock, nil
+}
+
+// startFilterProxy starts xdg-dbus-proxy in the background unless one is
+// already serving sock. It outlives igloo, so the bus keeps working for
+// GUI apps started from the container.
+func startFilterProxy(busAddr, sock string) error {
+	if conn, err := net.Dial("unix", sock); err == nil {
+		_ = conn.Close()
+		return nil
+	}
+
+	proxy, err := exec.LookPath("xdg-dbus-proxy")
+	if err != nil {
+		return fmt.Errorf("xdg-dbus-proxy is not installed on the host (install it, or set mode = full under [dbus])")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return err
+	}
+	_ = os.Remove(sock) // Left behind by a proxy that exited
+
+	cmd := exec.Command(proxy, ProxyArgs(busAddr, sock)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start xdg-dbus-proxy: %w", err)
+	}
+	_ = cmd.Process.Release()
+
+	// Wait for the proxy to create its socket
+	for range 20 {
+		if _, err := os.Stat(sock); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("xdg-dbus-proxy did not create %s", sock)
+}
@@ -0,0 +1,50 @@
+package dbus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		path     string
+		abstract bool
+		wantErr  bool
+	}{
+		{addr: "unix:path=/run/user/1000/bus", path: "/run/user/1000/bus"},
+		{addr: "unix:path=/run/user/1000/bus,guid=0123abcd", path: "/run/user/1000/bus"},
+		{addr: "unix:abstract=/tmp/dbus-XYZ,guid=0123abcd", path: "/tmp/dbus-XYZ", abstract: true},
+		{addr: "tcp:host=localhost,port=1234;unix:path=/tmp/bus", path: "/tmp/bus"},
+		{addr: "unix:path=/tmp/my%20bus", path: "/tmp/my bus"},
+		{addr: "tcp:host=localhost,port=1234", wantErr: true},
+		{addr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			path, abstract, err := ParseAddress(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.path || abstract != tt.abstract {
+				t.Errorf("ParseAddress() = (%q, %v), want (%q, %v)", path, abstract, tt.path, tt.abstract)
+			}
+		})
+	}
+}
+
+func TestProxyArgs(t *testing.T) {
+	got := ProxyArgs("unix:path=/run/user/1000/bus", "/run/user/1000/igloo/igloo-api-bus")
+	want := []string{
+		"unix:path=/run/user/1000/bus",
+		"/run/user/1000/igloo/igloo-api-bus",
+		"--filter",
+		"--talk=org.freedesktop.portal.*",
+		"--talk=org.freedesktop.Notifications",
+		"--talk=org.freedesktop.secrets",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProxyArgs() = %v, want %v", got, want)
+	}
+}
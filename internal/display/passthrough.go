@@ -62,7 +62,7 @@ func configureX11(client *incus.Client, name string, uid, gid int) error {
 			return fmt.Errorf("failed to add Xauthority mount: %w", err)
 		}
 	} else {
-		fmt.Printf("Note: No Xauthority file found at %s, X11 auth may fail\n", xauthFile)
+		_, _ = fmt.Fprintf(os.Stderr, "Note: No Xauthority file found at %s, X11 auth may fail\n", xauthFile)
 	}
 
 	// Set DISPLAY environment variable
@@ -99,7 +99,7 @@ func configureWayland(client *incus.Client, name string, uid, gid int) error {
 	if os.Getenv("DISPLAY") != "" {
 		if err := configureX11(client, name, uid, gid); err != nil {
 			// XWayland is optional, don't fail if it doesn't work
-			fmt.Printf("Note: XWayland setup skipped: %v\n", err)
+			_, _ = fmt.Fprintf(os.Stderr, "Note: XWayland setup skipped: %v\n", err)
 		}
 	}
 
@@ -0,0 +1,299 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/display"
+	"github.com/frostyard/igloo/internal/forward"
+)
+
+// Checks returns every check igloo doctor runs, in order
+func Checks() []Check {
+	return []Check{
+		{Name: "incus-installed", Run: checkIncusInstalled},
+		{Name: "incus-group", Run: checkIncusGroup},
+		{Name: "incus-daemon", Run: checkIncusDaemon},
+		{Name: "storage-pool", Run: checkStoragePool},
+		{Name: "network", Run: checkNetwork},
+		{Name: "idmapped-mounts", Run: checkIdmappedMounts},
+		{Name: "subuid", Run: checkSubIDs},
+		{Name: "host-os", Run: checkHostOS},
+		{Name: "xdg-runtime-dir", Run: checkRuntimeDir},
+		{Name: "display", Run: checkDisplay},
+		{Name: "gpg-signing", Run: checkGPGSigning},
+	}
+}
+
+// Groups that grant access to the incus daemon socket
+const (
+	adminGroup = "incus-admin" // Full access
+	userGroup  = "incus"       // Access to a restricted per-user project
+)
+
+func checkIncusInstalled(h *Host) Result {
+	if h.IncusPath == "" {
+		return Result{
+			Status:  StatusFail,
+			Message: "incus is not installed or not in PATH",
+			Fix:     "Install incus from your distribution's packages: https://linuxcontainers.org/incus/docs/main/installing/",
+		}
+	}
+	return Result{Status: StatusOK, Message: "incus found at " + h.IncusPath}
+}
+
+func checkIncusGroup(h *Host) Result {
+	if h.UID == 0 {
+		return Result{Status: StatusOK, Message: "running as root"}
+	}
+	if slices.Contains(h.Groups, adminGroup) {
+		return Result{Status: StatusOK, Message: fmt.Sprintf("%s is in the %s group", h.Username, adminGroup)}
+	}
+	fix := fmt.Sprintf("Run 'sudo usermod -aG %s %s', then log out and back in", adminGroup, h.Username)
+	if slices.Contains(h.ConfiguredGroups, adminGroup) {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("%s was added to the %s group, but this session doesn't have it yet", h.Username, adminGroup),
+			Fix:     "Log out and back in, or run 'newgrp " + adminGroup + "'",
+		}
+	}
+	if slices.Contains(h.Groups, userGroup) {
+		return Result{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("%s is only in the %s group, whose restricted project may refuse igloo's mounts", h.Username, userGroup),
+			Fix:     fix,
+		}
+	}
+	return Result{
+		Status:  StatusFail,
+		Message: fmt.Sprintf("%s is not in the %s group and can't talk to the incus daemon", h.Username, adminGroup),
+		Fix:     fix,
+	}
+}
+
+func checkIncusDaemon(h *Host) Result {
+	if h.IncusPath == "" {
+		return skipped("incus is not installed")
+	}
+	if h.Server == nil {
+		msg := "the incus daemon is not reachable"
+		if h.ServerErr != nil {
+			msg += ": " + h.ServerErr.Error()
+		}
+		return Result{
+			Status:  StatusFail,
+			Message: msg,
+			Fix:     "Start it with 'sudo systemctl enable --now incus.socket'",
+		}
+	}
+	if h.Server.Auth != "trusted" {
+		return Result{
+			Status:  StatusFail,
+			Message: "the incus daemon doesn't trust this user",
+			Fix:     fmt.Sprintf("Run 'sudo usermod -aG %s %s', then log out and back in", adminGroup, h.Username),
+		}
+	}
+	return Result{Status: StatusOK, Message: "incus " + h.Server.Environment.ServerVersion + " is running"}
+}
+
+func checkStoragePool(h *Host) Result {
+	if r, ok := needProfile(h); !ok {
+		return r
+	}
+	for _, dev := range h.DefaultProfile.Devices {
+		if dev["type"] == "disk" && dev["path"] == "/" && dev["pool"] != "" {
+			return Result{Status: StatusOK, Message: "default profile uses storage pool " + dev["pool"]}
+		}
+	}
+	return Result{
+		Status:  StatusFail,
+		Message: "the default profile has no root disk, so incus was probably never initialized",
+		Fix:     "Run 'incus admin init --minimal', or add a pool with 'incus storage create default dir' and 'incus profile device add default root disk path=/ pool=default'",
+	}
+}
+
+func checkNetwork(h *Host) Result {
+	if r, ok := needProfile(h); !ok {
+		return r
+	}
+	for _, dev := range h.DefaultProfile.Devices {
+		if dev["type"] == "nic" {
+			network := dev["network"]
+			if network == "" {
+				network = dev["parent"]
+			}
+			return Result{Status: StatusOK, Message: "default profile is connected to " + network}
+		}
+	}
+	return Result{
+		Status:  StatusFail,
+		Message: "the default profile has no network device, so containers can't install packages",
+		Fix:     "Run 'incus admin init --minimal', or add one with 'incus network create incusbr0' and 'incus profile device add default eth0 nic network=incusbr0'",
+	}
+}
+
+func checkIdmappedMounts(h *Host) Result {
+	if h.Server == nil {
+		return skipped("the incus daemon is not reachable")
+	}
+	features := h.Server.Environment.KernelFeatures
+	if features["idmapped_mounts"] == "true" {
+		return Result{Status: StatusOK, Message: "kernel supports idmapped mounts"}
+	}
+	if features["shiftfs"] == "true" {
+		return Result{Status: StatusOK, Message: "shiftfs is available"}
+	}
+	return Result{
+		Status:  StatusFail,
+		Message: fmt.Sprintf("kernel %s supports neither idmapped mounts nor shiftfs, so the home and project mounts (shift=true) will fail", h.Server.Environment.KernelVersion),
+		Fix:     "Upgrade to a kernel with idmapped mount support (5.12 or newer) on a filesystem that supports it",
+	}
+}
+
+// subIDFiles are the files that delegate subordinate IDs to the incus daemon
+var subIDFiles = []string{"/etc/subuid", "/etc/subgid"}
+
+func checkSubIDs(h *Host) Result {
+	var missing []string
+	for _, path := range subIDFiles {
+		data, err := h.ReadFile(path)
+		if err != nil {
+			// Without the files incus falls back to its built-in range
+			continue
+		}
+		if !hasSubIDEntry(string(data), "root") {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		return Result{
+			Status:  StatusFail,
+			Message: "no entry for root in " + strings.Join(missing, " and ") + ", so incus can't map container IDs",
+			Fix:     fmt.Sprintf("Run 'echo root:1000000:1000000000 | sudo tee -a %s', then 'sudo systemctl restart incus'", strings.Join(missing, " ")),
+		}
+	}
+	return Result{Status: StatusOK, Message: "subordinate IDs are delegated to incus"}
+}
+
+// hasSubIDEntry reports whether a subuid/subgid file delegates IDs to owner
+func hasSubIDEntry(data, owner string) bool {
+	for line := range strings.Lines(data) {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) == 3 && fields[0] == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func checkHostOS(h *Host) Result {
+	if h.OSID != "" && !config.IsDistroSupported(h.OSID) {
+		return Result{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("host distro %s has no igloo image; igloo init defaults to %s/%s", h.OSID, h.Distro, h.Release),
+			Fix:     "Pass --distro and --release to igloo init to choose another image",
+		}
+	}
+	return Result{Status: StatusOK, Message: fmt.Sprintf("igloo init defaults to %s/%s", h.Distro, h.Release)}
+}
+
+func checkRuntimeDir(h *Host) Result {
+	if h.RuntimeDir == "" {
+		return Result{
+			Status:  StatusWarn,
+			Message: "XDG_RUNTIME_DIR is not set, so Wayland passthrough won't work",
+			Fix:     "Log in through a systemd session, or 'export XDG_RUNTIME_DIR=/run/user/$(id -u)'",
+		}
+	}
+	if !h.Exists(h.RuntimeDir) {
+		return Result{
+			Status:  StatusWarn,
+			Message: "XDG_RUNTIME_DIR is set to " + h.RuntimeDir + ", which doesn't exist",
+			Fix:     "Log in through a systemd session so the runtime directory is created",
+		}
+	}
+	return Result{Status: StatusOK, Message: "XDG_RUNTIME_DIR is " + h.RuntimeDir}
+}
+
+func checkDisplay(h *Host) Result {
+	switch h.Display {
+	case display.Wayland:
+		if !h.Exists(h.WaylandSocket) {
+			return Result{
+				Status:  StatusFail,
+				Message: "WAYLAND_DISPLAY is set, but " + h.WaylandSocket + " doesn't exist",
+				Fix:     "Check WAYLAND_DISPLAY and XDG_RUNTIME_DIR match your compositor's socket",
+			}
+		}
+		return Result{Status: StatusOK, Message: "Wayland socket " + h.WaylandSocket}
+	case display.X11:
+		if !h.Exists(h.X11Socket) {
+			return Result{
+				Status:  StatusFail,
+				Message: "DISPLAY is set, but " + h.X11Socket + " doesn't exist",
+				Fix:     "igloo needs a local X server socket; remote DISPLAYs such as SSH forwarding aren't supported",
+			}
+		}
+		return Result{Status: StatusOK, Message: "X11 socket " + h.X11Socket}
+	default:
+		return Result{
+			Status:  StatusWarn,
+			Message: "no display server detected, so GUI apps won't work",
+			Fix:     "Run igloo from a graphical session, or set enabled = false under [display] in igloo.ini",
+		}
+	}
+}
+
+func checkGPGSigning(h *Host) Result {
+	if !h.GPGForward {
+		return skipped("gpg_agent forwarding is not enabled for this project")
+	}
+	if h.GPGSocket == "" {
+		fix := "Start the agent with 'gpgconf --launch gpg-agent'"
+		if errors.Is(h.GPGErr, forward.ErrNoGPG) {
+			fix = "Install gnupg on the host"
+		}
+		msg := "the host gpg-agent can't be forwarded"
+		if h.GPGErr != nil {
+			msg += ": " + h.GPGErr.Error()
+		}
+		return Result{Status: StatusFail, Message: msg, Fix: fix}
+	}
+	if h.Container == "" || h.SignTest == nil {
+		return skipped("the container isn't running; run 'igloo start' and check again")
+	}
+	if err := h.SignTest(); err != nil {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("signing inside %s failed: %v", h.Container, err),
+			Fix:     "Make sure the signing key (git config user.signingkey) is in the host keyring, then run 'igloo enter' to refresh the forwarded socket and public keys",
+		}
+	}
+	return Result{Status: StatusOK, Message: "commits can be signed inside " + h.Container}
+}
+
+// needProfile skips checks of the default profile when it couldn't be read
+func needProfile(h *Host) (Result, bool) {
+	if h.Server == nil {
+		return skipped("the incus daemon is not reachable"), false
+	}
+	if h.DefaultProfile == nil {
+		msg := "the default profile couldn't be read"
+		if h.ProfileErr != nil {
+			msg += ": " + h.ProfileErr.Error()
+		}
+		return Result{
+			Status:  StatusFail,
+			Message: msg,
+			Fix:     "Run 'incus admin init --minimal' to create it",
+		}, false
+	}
+	return Result{}, true
+}
+
+func skipped(reason string) Result {
+	return Result{Status: StatusSkip, Message: "skipped: " + reason}
+}
@@ -0,0 +1,152 @@
+// Package doctor checks that the host is set up to run igloo environments.
+package doctor
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/display"
+	"github.com/frostyard/igloo/internal/forward"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn" // igloo works, but some features won't
+	StatusFail Status = "fail" // igloo won't work until this is fixed
+	StatusSkip Status = "skip" // A check it depends on failed
+)
+
+// Result is the outcome of a check, with a suggested fix when it didn't pass
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// Check is one prerequisite igloo needs from the host. Checks only look at
+// the Host they are given, so they can be run against fake inputs.
+type Check struct {
+	Name string
+	Run  func(h *Host) Result
+}
+
+// Host is a snapshot of everything the checks inspect
+type Host struct {
+	Username         string
+	UID              int
+	Groups           []string // Groups of the current session
+	ConfiguredGroups []string // Groups in /etc/group, which may include ones not yet active
+
+	OSID    string // ID from /etc/os-release
+	Distro  string // Distro igloo init would default to
+	Release string // Release igloo init would default to
+
+	IncusPath      string            // Path of the incus binary, empty if not installed
+	Server         *incus.ServerInfo // nil if the daemon couldn't be reached
+	ServerErr      error
+	DefaultProfile *incus.Profile // nil if it couldn't be read
+	ProfileErr     error
+
+	Display       display.Type
+	RuntimeDir    string // XDG_RUNTIME_DIR, empty if unset
+	WaylandSocket string // Path of the Wayland socket the container would use
+	X11Socket     string // Path of the X11 socket the container would use
+
+	GPGSocket string // Host gpg-agent extra socket, empty if unavailable
+	GPGErr    error  // Why the extra socket is unavailable
+
+	// The current project, if doctor runs inside one that forwards gpg-agent
+	GPGForward bool         // The project forwards gpg-agent
+	Container  string       // The project's container, if it is running
+	SignTest   func() error // Signs and verifies a message inside Container
+
+	// Exists reports whether a path exists
+	Exists func(path string) bool
+	// ReadFile reads a file such as /etc/subuid
+	ReadFile func(path string) ([]byte, error)
+}
+
+// DetectHost inspects the running host, querying incus through client
+func DetectHost(client *incus.Client) *Host {
+	h := &Host{
+		Username:   os.Getenv("USER"),
+		UID:        os.Getuid(),
+		OSID:       config.HostOSID(),
+		Display:    display.Detect(),
+		RuntimeDir: os.Getenv("XDG_RUNTIME_DIR"),
+		X11Socket:  "/tmp/.X11-unix/X" + display.GetX11Display(),
+		Exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+		ReadFile: os.ReadFile,
+	}
+	h.Distro, h.Release = config.DetectHostOS()
+	h.WaylandSocket = filepath.Join(display.GetXDGRuntimeDir(), display.GetWaylandDisplay())
+	h.GPGSocket, h.GPGErr = forward.HostGPGExtraSocket()
+
+	if gids, err := os.Getgroups(); err == nil {
+		ids := make([]string, len(gids))
+		for i, gid := range gids {
+			ids[i] = strconv.Itoa(gid)
+		}
+		h.Groups = groupNames(ids)
+	}
+	if u, err := user.Current(); err == nil {
+		if gids, err := u.GroupIds(); err == nil {
+			h.ConfiguredGroups = groupNames(gids)
+		}
+	}
+
+	if path, err := exec.LookPath("incus"); err == nil {
+		h.IncusPath = path
+		h.Server, h.ServerErr = client.ServerInfo()
+		if h.ServerErr == nil {
+			h.DefaultProfile, h.ProfileErr = client.GetProfile("default")
+		}
+	}
+
+	return h
+}
+
+// groupNames resolves group IDs to names, skipping any that can't be resolved
+func groupNames(gids []string) []string {
+	var names []string
+	for _, gid := range gids {
+		if g, err := user.LookupGroupId(gid); err == nil {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// Run runs every check against h, in order
+func Run(h *Host, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		r := c.Run(h)
+		r.Name = c.Name
+		results = append(results, r)
+	}
+	return results
+}
+
+// Failed returns the number of results that failed
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
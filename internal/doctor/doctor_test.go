@@ -0,0 +1,314 @@
+package doctor
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/frostyard/igloo/internal/display"
+	"github.com/frostyard/igloo/internal/forward"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// healthyHost returns a host on which every check passes
+func healthyHost() *Host {
+	server := &incus.ServerInfo{Auth: "trusted"}
+	server.Environment.ServerVersion = "6.0"
+	server.Environment.KernelVersion = "6.8.0"
+	server.Environment.KernelFeatures = map[string]string{"idmapped_mounts": "true", "shiftfs": "false"}
+
+	files := map[string]string{
+		"/etc/subuid": "root:1000000:1000000000\n",
+		"/etc/subgid": "root:1000000:1000000000\n",
+	}
+	paths := map[string]bool{
+		"/run/user/1000":           true,
+		"/run/user/1000/wayland-0": true,
+	}
+
+	return &Host{
+		Username:         "alice",
+		UID:              1000,
+		Groups:           []string{"alice", "incus-admin"},
+		ConfiguredGroups: []string{"alice", "incus-admin"},
+		OSID:             "debian",
+		Distro:           "debian",
+		Release:          "trixie",
+		IncusPath:        "/usr/bin/incus",
+		Server:           server,
+		DefaultProfile: &incus.Profile{
+			Name: "default",
+			Devices: map[string]map[string]string{
+				"root": {"type": "disk", "path": "/", "pool": "default"},
+				"eth0": {"type": "nic", "network": "incusbr0", "name": "eth0"},
+			},
+		},
+		Display:       display.Wayland,
+		RuntimeDir:    "/run/user/1000",
+		WaylandSocket: "/run/user/1000/wayland-0",
+		X11Socket:     "/tmp/.X11-unix/X0",
+		GPGSocket:     "/run/user/1000/gnupg/S.gpg-agent.extra",
+		GPGForward:    true,
+		Container:     "igloo-api",
+		SignTest:      func() error { return nil },
+		Exists:        func(path string) bool { return paths[path] },
+		ReadFile: func(path string) ([]byte, error) {
+			if data, ok := files[path]; ok {
+				return []byte(data), nil
+			}
+			return nil, fs.ErrNotExist
+		},
+	}
+}
+
+func TestChecksHealthyHost(t *testing.T) {
+	for _, r := range Run(healthyHost(), Checks()) {
+		if r.Status != StatusOK {
+			t.Errorf("%s: status = %s (%s), want ok", r.Name, r.Status, r.Message)
+		}
+	}
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  func(h *Host) Result
+		modify func(h *Host)
+		want   Status
+		fixHas string // Substring the suggested fix must contain
+	}{
+		{
+			name:   "incus not installed",
+			check:  checkIncusInstalled,
+			modify: func(h *Host) { h.IncusPath = "" },
+			want:   StatusFail,
+			fixHas: "Install incus",
+		},
+		{
+			name:   "not in incus-admin group",
+			check:  checkIncusGroup,
+			modify: func(h *Host) { h.Groups = []string{"alice"}; h.ConfiguredGroups = h.Groups },
+			want:   StatusFail,
+			fixHas: "usermod -aG incus-admin alice",
+		},
+		{
+			name:   "group added but session not refreshed",
+			check:  checkIncusGroup,
+			modify: func(h *Host) { h.Groups = []string{"alice"} },
+			want:   StatusFail,
+			fixHas: "newgrp",
+		},
+		{
+			name:   "only in restricted incus group",
+			check:  checkIncusGroup,
+			modify: func(h *Host) { h.Groups = []string{"alice", "incus"}; h.ConfiguredGroups = h.Groups },
+			want:   StatusWarn,
+		},
+		{
+			name:   "root needs no group",
+			check:  checkIncusGroup,
+			modify: func(h *Host) { h.UID = 0; h.Groups = nil },
+			want:   StatusOK,
+		},
+		{
+			name:  "daemon not reachable",
+			check: checkIncusDaemon,
+			modify: func(h *Host) {
+				h.Server = nil
+				h.ServerErr = errors.New("permission denied")
+			},
+			want:   StatusFail,
+			fixHas: "systemctl",
+		},
+		{
+			name:   "daemon check skipped without incus",
+			check:  checkIncusDaemon,
+			modify: func(h *Host) { h.IncusPath = ""; h.Server = nil },
+			want:   StatusSkip,
+		},
+		{
+			name:   "untrusted client",
+			check:  checkIncusDaemon,
+			modify: func(h *Host) { h.Server.Auth = "untrusted" },
+			want:   StatusFail,
+		},
+		{
+			name:   "no root disk",
+			check:  checkStoragePool,
+			modify: func(h *Host) { delete(h.DefaultProfile.Devices, "root") },
+			want:   StatusFail,
+			fixHas: "incus admin init",
+		},
+		{
+			name:   "missing default profile",
+			check:  checkStoragePool,
+			modify: func(h *Host) { h.DefaultProfile = nil },
+			want:   StatusFail,
+		},
+		{
+			name:   "no network",
+			check:  checkNetwork,
+			modify: func(h *Host) { delete(h.DefaultProfile.Devices, "eth0") },
+			want:   StatusFail,
+			fixHas: "incus network create",
+		},
+		{
+			name:   "network check skipped without daemon",
+			check:  checkNetwork,
+			modify: func(h *Host) { h.Server = nil },
+			want:   StatusSkip,
+		},
+		{
+			name:  "no idmap support",
+			check: checkIdmappedMounts,
+			modify: func(h *Host) {
+				h.Server.Environment.KernelFeatures = map[string]string{"idmapped_mounts": "false", "shiftfs": "false"}
+			},
+			want: StatusFail,
+		},
+		{
+			name:  "shiftfs instead of idmapped mounts",
+			check: checkIdmappedMounts,
+			modify: func(h *Host) {
+				h.Server.Environment.KernelFeatures = map[string]string{"idmapped_mounts": "false", "shiftfs": "true"}
+			},
+			want: StatusOK,
+		},
+		{
+			name:  "subuid without root entry",
+			check: checkSubIDs,
+			modify: func(h *Host) {
+				h.ReadFile = func(path string) ([]byte, error) {
+					if path == "/etc/subuid" {
+						return []byte("alice:100000:65536\n"), nil
+					}
+					return []byte("root:1000000:1000000000\n"), nil
+				}
+			},
+			want:   StatusFail,
+			fixHas: "/etc/subuid",
+		},
+		{
+			name:  "no subuid files",
+			check: checkSubIDs,
+			modify: func(h *Host) {
+				h.ReadFile = func(string) ([]byte, error) { return nil, fs.ErrNotExist }
+			},
+			want: StatusOK,
+		},
+		{
+			name:   "unsupported host distro",
+			check:  checkHostOS,
+			modify: func(h *Host) { h.OSID = "gentoo"; h.Distro = "ubuntu"; h.Release = "questing" },
+			want:   StatusWarn,
+			fixHas: "--distro",
+		},
+		{
+			name:   "XDG_RUNTIME_DIR unset",
+			check:  checkRuntimeDir,
+			modify: func(h *Host) { h.RuntimeDir = "" },
+			want:   StatusWarn,
+		},
+		{
+			name:   "XDG_RUNTIME_DIR missing",
+			check:  checkRuntimeDir,
+			modify: func(h *Host) { h.RuntimeDir = "/run/user/1234" },
+			want:   StatusWarn,
+		},
+		{
+			name:   "Wayland socket missing",
+			check:  checkDisplay,
+			modify: func(h *Host) { h.WaylandSocket = "/run/user/1000/wayland-1" },
+			want:   StatusFail,
+		},
+		{
+			name:   "X11 socket missing",
+			check:  checkDisplay,
+			modify: func(h *Host) { h.Display = display.X11 },
+			want:   StatusFail,
+		},
+		{
+			name:   "no display",
+			check:  checkDisplay,
+			modify: func(h *Host) { h.Display = display.None },
+			want:   StatusWarn,
+		},
+		{
+			name:   "gpg forwarding not enabled",
+			check:  checkGPGSigning,
+			modify: func(h *Host) { h.GPGForward = false },
+			want:   StatusSkip,
+		},
+		{
+			name:  "no gpg on host",
+			check: checkGPGSigning,
+			modify: func(h *Host) {
+				h.GPGSocket = ""
+				h.GPGErr = forward.ErrNoGPG
+			},
+			want:   StatusFail,
+			fixHas: "Install gnupg",
+		},
+		{
+			name:   "gpg-agent not running",
+			check:  checkGPGSigning,
+			modify: func(h *Host) { h.GPGSocket = "" },
+			want:   StatusFail,
+			fixHas: "gpgconf --launch",
+		},
+		{
+			name:   "container stopped",
+			check:  checkGPGSigning,
+			modify: func(h *Host) { h.Container = ""; h.SignTest = nil },
+			want:   StatusSkip,
+		},
+		{
+			name:  "signing fails",
+			check: checkGPGSigning,
+			modify: func(h *Host) {
+				h.SignTest = func() error { return errors.New("gpg: signing failed: No secret key") }
+			},
+			want:   StatusFail,
+			fixHas: "user.signingkey",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := healthyHost()
+			tt.modify(h)
+			r := tt.check(h)
+			if r.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", r.Status, r.Message, tt.want)
+			}
+			if tt.want != StatusOK && tt.want != StatusSkip && r.Fix == "" {
+				t.Error("expected a suggested fix")
+			}
+			if !strings.Contains(r.Fix, tt.fixHas) {
+				t.Errorf("fix = %q, want it to contain %q", r.Fix, tt.fixHas)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "first", Run: func(*Host) Result { return Result{Status: StatusOK} }},
+		{Name: "second", Run: func(*Host) Result { return Result{Status: StatusFail} }},
+		{Name: "third", Run: func(*Host) Result { return Result{Status: StatusWarn} }},
+	}
+
+	results := Run(healthyHost(), checks)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, name := range []string{"first", "second", "third"} {
+		if results[i].Name != name {
+			t.Errorf("results[%d].Name = %q, want %q", i, results[i].Name, name)
+		}
+	}
+	if n := Failed(results); n != 1 {
+		t.Errorf("Failed() = %d, want 1", n)
+	}
+}
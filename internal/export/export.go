@@ -0,0 +1,246 @@
+// Package export exposes apps and commands installed in an igloo container
+// on the host: desktop launchers for the host's app menu and shims in
+// ~/.local/bin, both of which run the program through 'igloo exec'.
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DesktopDirs are the container directories searched for desktop entries
+var DesktopDirs = []string{
+	"/usr/local/share/applications",
+	"/usr/share/applications",
+	"/var/lib/flatpak/exports/share/applications",
+	"/var/lib/snapd/desktop/applications",
+}
+
+// IconDirs are the container directories searched for icons
+var IconDirs = []string{
+	"/usr/share/icons",
+	"/usr/local/share/icons",
+	"/usr/share/pixmaps",
+}
+
+// shimMarker identifies a ~/.local/bin shim written by igloo, so it is never
+// mistaken for, or allowed to replace, a file the user put there
+const shimMarker = "# Exported by igloo"
+
+// DesktopID returns a desktop entry ID without its .desktop suffix
+func DesktopID(id string) string {
+	return strings.TrimSuffix(id, ".desktop")
+}
+
+func dataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "share")
+}
+
+// DesktopPath returns where an exported desktop entry is written on the host
+func DesktopPath(container, id string) string {
+	return filepath.Join(dataHome(), "applications", fmt.Sprintf("igloo-%s-%s.desktop", container, DesktopID(id)))
+}
+
+// IconPath returns where an exported icon is copied on the host
+func IconPath(container, icon string) string {
+	return filepath.Join(dataHome(), "igloo", "icons", container, filepath.Base(icon))
+}
+
+// BinPath returns where an exported command's shim is written on the host
+func BinPath(name string) string {
+	return filepath.Join(os.Getenv("HOME"), ".local", "bin", name)
+}
+
+// Launcher describes how an exported app is run on the host
+type Launcher struct {
+	Container  string // Container the app is installed in
+	Igloo      string // Absolute path of the host igloo binary
+	ProjectDir string // Project the container belongs to
+	Icon       string // Host path of the copied icon, or "" to keep the icon name
+}
+
+// DesktopIcon returns the Icon key of a desktop entry's main group
+func DesktopIcon(content string) string {
+	group := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			group = line
+			continue
+		}
+		if group == "[Desktop Entry]" {
+			if value, ok := strings.CutPrefix(line, "Icon="); ok {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+// RewriteDesktop rewrites a desktop entry from the container so it runs
+// through igloo on the host. Every Exec line, including those of desktop
+// actions, is prefixed with 'igloo exec --'; the working directory is set to
+// the project so igloo finds it; names are suffixed with the container; and
+// keys that only make sense inside the container are dropped.
+func RewriteDesktop(content string, l Launcher) string {
+	var out []string
+	group := ""
+	// Added keys go after the main group's last key, before any blank lines
+	endMain := func() {
+		end := len(out)
+		for end > 0 && strings.TrimSpace(out[end-1]) == "" {
+			end--
+		}
+		added := []string{"Path=" + l.ProjectDir, "X-Igloo-Container=" + l.Container}
+		out = slices.Insert(out, end, added...)
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if group == "[Desktop Entry]" {
+				endMain()
+			}
+			group = trimmed
+			out = append(out, line)
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		key = strings.TrimSpace(key)
+
+		switch {
+		case key == "Exec":
+			out = append(out, "Exec="+quoteExec(l.Igloo)+" exec -- "+strings.TrimSpace(value))
+		case key == "TryExec", key == "DBusActivatable", key == "Path", key == "X-Igloo-Container":
+			// Dropped: the program and its D-Bus service only exist in the
+			// container, and igloo sets its own Path
+		case group == "[Desktop Entry]" && (key == "Name" || strings.HasPrefix(key, "Name[")):
+			out = append(out, fmt.Sprintf("%s=%s (%s)", key, strings.TrimSpace(value), l.Container))
+		case group == "[Desktop Entry]" && key == "Icon" && l.Icon != "":
+			out = append(out, "Icon="+l.Icon)
+		default:
+			out = append(out, line)
+		}
+	}
+	if group == "[Desktop Entry]" {
+		endMain()
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// quoteExec quotes a path for an Exec key, following the desktop entry spec
+func quoteExec(path string) string {
+	if !strings.ContainsAny(path, " \t\"'\\><~|&;$*?#()`") {
+		return path
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	return `"` + r.Replace(path) + `"`
+}
+
+// iconSize matches the size directory of an icon theme path, such as 48x48
+var iconSize = regexp.MustCompile(`/(\d+)x\d+(@\d+)?/`)
+
+// BestIcon picks the icon to export from the candidates found in the
+// container: a scalable icon if there is one, otherwise the largest bitmap
+func BestIcon(paths []string) string {
+	best := ""
+	bestSize := -1
+	for _, path := range paths {
+		size := 0
+		switch {
+		case strings.HasSuffix(path, ".svg"):
+			size = 1 << 16
+		case iconSize.MatchString(path):
+			size, _ = strconv.Atoi(iconSize.FindStringSubmatch(path)[1])
+		}
+		if size > bestSize {
+			best, bestSize = path, size
+		}
+	}
+	return best
+}
+
+// BinShim returns a shim that runs a container command through igloo. From
+// inside the project it keeps the current directory, which igloo exec maps
+// into the container; from anywhere else it runs at the project root.
+func BinShim(container, name, igloo, projectDir string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s from %s; remove with 'igloo unexport bin %s'
+case "$PWD/" in
+%s/*) ;;
+*) cd %s || exit 1 ;;
+esac
+exec %s exec -- %s "$@"
+`, shimMarker, container, name, shellQuote(projectDir), shellQuote(projectDir), shellQuote(igloo), shellQuote(name))
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ErrNotOurs is returned when a shim would replace a file igloo didn't write
+var ErrNotOurs = errors.New("file exists and was not exported by igloo")
+
+// ErrOtherContainer is returned when a shim would replace one exported from
+// another container, whose registry entry still lists it
+var ErrOtherContainer = errors.New("exported from another container")
+
+// WriteBin writes container's shim, refusing to replace anything but an
+// earlier shim from the same container
+func WriteBin(path, container, shim string) error {
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // Shebang
+		scanner.Scan()
+		line := scanner.Text()
+		_ = f.Close()
+		if !strings.HasPrefix(line, shimMarker) {
+			return fmt.Errorf("%s: %w", path, ErrNotOurs)
+		}
+		owner, _, _ := strings.Cut(strings.TrimPrefix(line, shimMarker+" from "), ";")
+		if owner != container {
+			return fmt.Errorf("%s: %w %s; run 'igloo unexport bin %s' in its project first", path, ErrOtherContainer, owner, filepath.Base(path))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(shim), 0755)
+}
+
+// WriteFile writes an exported file, creating its directory
+func WriteFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Remove deletes the host files of an export. Files that are already gone
+// are not an error.
+func Remove(files []string) error {
+	var errs []error
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const codeDesktop = `[Desktop Entry]
+Name=Visual Studio Code
+Name[de]=Visual Studio Code
+GenericName=Text Editor
+Exec=/usr/share/code/code %F
+TryExec=/usr/share/code/code
+Icon=vscode
+Type=Application
+Actions=new-empty-window;
+
+[Desktop Action new-empty-window]
+Name=New Empty Window
+Exec=/usr/share/code/code --new-window %F
+Icon=vscode
+`
+
+func TestDesktopIcon(t *testing.T) {
+	if got := DesktopIcon(codeDesktop); got != "vscode" {
+		t.Errorf("DesktopIcon() = %q, want %q", got, "vscode")
+	}
+	if got := DesktopIcon("[Desktop Entry]\nName=x\n"); got != "" {
+		t.Errorf("DesktopIcon() without icon = %q, want empty", got)
+	}
+}
+
+func TestRewriteDesktop(t *testing.T) {
+	got := RewriteDesktop(codeDesktop, Launcher{
+		Container:  "igloo-api",
+		Igloo:      "/usr/local/bin/igloo",
+		ProjectDir: "/home/dev/work/api",
+		Icon:       "/home/dev/.local/share/igloo/icons/igloo-api/vscode.svg",
+	})
+
+	want := `[Desktop Entry]
+Name=Visual Studio Code (igloo-api)
+Name[de]=Visual Studio Code (igloo-api)
+GenericName=Text Editor
+Exec=/usr/local/bin/igloo exec -- /usr/share/code/code %F
+Icon=/home/dev/.local/share/igloo/icons/igloo-api/vscode.svg
+Type=Application
+Actions=new-empty-window;
+Path=/home/dev/work/api
+X-Igloo-Container=igloo-api
+
+[Desktop Action new-empty-window]
+Name=New Empty Window
+Exec=/usr/local/bin/igloo exec -- /usr/share/code/code --new-window %F
+Icon=vscode
+`
+	if got != want {
+		t.Errorf("RewriteDesktop() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRewriteDesktop_QuotesIgloo(t *testing.T) {
+	got := RewriteDesktop("[Desktop Entry]\nExec=jq\n", Launcher{
+		Container:  "igloo-api",
+		Igloo:      "/opt/my tools/igloo",
+		ProjectDir: "/srv/api",
+	})
+	if !strings.Contains(got, `Exec="/opt/my tools/igloo" exec -- jq`) {
+		t.Errorf("RewriteDesktop() did not quote the igloo path:\n%s", got)
+	}
+}
+
+func TestBestIcon(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{
+			paths: []string{
+				"/usr/share/icons/hicolor/48x48/apps/vscode.png",
+				"/usr/share/icons/hicolor/512x512/apps/vscode.png",
+				"/usr/share/icons/hicolor/128x128/apps/vscode.png",
+			},
+			want: "/usr/share/icons/hicolor/512x512/apps/vscode.png",
+		},
+		{
+			paths: []string{
+				"/usr/share/pixmaps/vscode.png",
+				"/usr/share/icons/hicolor/scalable/apps/vscode.svg",
+				"/usr/share/icons/hicolor/256x256/apps/vscode.png",
+			},
+			want: "/usr/share/icons/hicolor/scalable/apps/vscode.svg",
+		},
+		{paths: []string{"/usr/share/pixmaps/vscode.png"}, want: "/usr/share/pixmaps/vscode.png"},
+		{paths: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := BestIcon(tt.paths); got != tt.want {
+			t.Errorf("BestIcon(%v) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	if got, want := DesktopPath("igloo-api", "code.desktop"), "/home/dev/.local/share/applications/igloo-igloo-api-code.desktop"; got != want {
+		t.Errorf("DesktopPath() = %q, want %q", got, want)
+	}
+	if got, want := IconPath("igloo-api", "/usr/share/pixmaps/code.png"), "/home/dev/.local/share/igloo/icons/igloo-api/code.png"; got != want {
+		t.Errorf("IconPath() = %q, want %q", got, want)
+	}
+	if got, want := BinPath("jq"), "/home/dev/.local/bin/jq"; got != want {
+		t.Errorf("BinPath() = %q, want %q", got, want)
+	}
+}
+
+func TestBinShim(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "it's a project")
+	elsewhere := filepath.Join(dir, "elsewhere")
+	for _, d := range []string{filepath.Join(project, "src"), elsewhere} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A fake igloo that reports where it ran and with which arguments
+	igloo := filepath.Join(dir, "igloo")
+	if err := os.WriteFile(igloo, []byte("#!/bin/sh\necho \"$PWD|$*\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	shim := filepath.Join(dir, "jq")
+	if err := WriteBin(shim, "igloo-api", BinShim("igloo-api", "jq", igloo, project)); err != nil {
+		t.Fatalf("WriteBin() error: %v", err)
+	}
+
+	tests := map[string]string{
+		filepath.Join(project, "src"): filepath.Join(project, "src") + "|exec -- jq -r .name",
+		elsewhere:                     project + "|exec -- jq -r .name",
+	}
+	for cwd, want := range tests {
+		cmd := exec.Command(shim, "-r", ".name")
+		cmd.Dir = cwd
+		cmd.Env = append(os.Environ(), "PWD="+cwd)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("shim failed in %s: %v\n%s", cwd, err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("shim in %s ran %q, want %q", cwd, got, want)
+		}
+	}
+
+	// Re-exporting replaces the shim, but another igloo's shim and a user's
+	// own file are left alone
+	if err := WriteBin(shim, "igloo-api", BinShim("igloo-api", "jq", igloo, project)); err != nil {
+		t.Errorf("WriteBin() over an earlier shim: %v", err)
+	}
+	if err := WriteBin(shim, "igloo-web", BinShim("igloo-web", "jq", igloo, project)); !errors.Is(err, ErrOtherContainer) {
+		t.Errorf("WriteBin() over another container's shim: error = %v, want ErrOtherContainer", err)
+	}
+	if err := os.WriteFile(shim, []byte("#!/bin/sh\necho mine\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteBin(shim, "igloo-api", BinShim("igloo-api", "jq", igloo, project)); !errors.Is(err, ErrNotOurs) {
+		t.Errorf("WriteBin() over a user file: error = %v, want ErrNotOurs", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.desktop")
+	if err := WriteFile(file, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove([]string{file, filepath.Join(dir, "missing.png")}); err != nil {
+		t.Errorf("Remove() error: %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("Remove() left the file behind")
+	}
+}
@@ -0,0 +1,111 @@
+package forward
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// ErrNoAudio is returned when no sound server socket is found on the host
+var ErrNoAudio = errors.New("no PipeWire or PulseAudio socket found in $XDG_RUNTIME_DIR")
+
+// AudioServer is a sound server socket that can be forwarded
+type AudioServer struct {
+	Name   string // Sound server, for messages
+	Device string // Proxy device name
+	Socket string // Socket path relative to XDG_RUNTIME_DIR, on both sides
+	EnvKey string // Instance environment variable that points clients at it
+	envFmt string // Value of EnvKey, formatted with the container socket path
+}
+
+// ContainerSocket returns where the socket appears in the container
+func (a AudioServer) ContainerSocket(uid int) string {
+	return filepath.Join(fmt.Sprintf("/run/user/%d", uid), a.Socket)
+}
+
+// EnvValue returns the value of the environment variable for the container
+func (a AudioServer) EnvValue(uid int) string {
+	return fmt.Sprintf(a.envFmt, a.ContainerSocket(uid))
+}
+
+// AudioServers are the sound server sockets igloo knows how to forward.
+// PipeWire hosts usually run pipewire-pulse too, and both are forwarded so
+// native and PulseAudio clients work.
+var AudioServers = []AudioServer{
+	{
+		Name:   "PipeWire",
+		Device: "pipewire",
+		Socket: "pipewire-0",
+		EnvKey: "environment.PIPEWIRE_REMOTE",
+		envFmt: "%s",
+	},
+	{
+		Name:   "PulseAudio",
+		Device: "pulseaudio",
+		Socket: "pulse/native",
+		EnvKey: "environment.PULSE_SERVER",
+		envFmt: "unix:%s",
+	},
+}
+
+// DetectAudio returns the sound servers with a socket in runtimeDir
+func DetectAudio(runtimeDir string) []AudioServer {
+	if runtimeDir == "" {
+		return nil
+	}
+	var found []AudioServer
+	for _, a := range AudioServers {
+		if checkSocket(filepath.Join(runtimeDir, a.Socket)) == nil {
+			found = append(found, a)
+		}
+	}
+	return found
+}
+
+// Audio forwards the host's sound server sockets into the container and
+// points clients at them. Like the agents, sockets are re-checked on every
+// enter: servers that have gone away are removed, and ErrNoAudio is returned
+// if none are left.
+func Audio(client *incus.Client, name string) error {
+	uid := os.Getuid()
+	gid := os.Getgid()
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+
+	found := make(map[string]bool)
+	for _, a := range DetectAudio(runtimeDir) {
+		found[a.Device] = true
+	}
+
+	for _, a := range AudioServers {
+		if !found[a.Device] {
+			if err := hostsock.Remove(client, name, a.Device, a.EnvKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Sockets in subdirectories, such as pulse/native, need the
+		// directory to exist in the container first
+		containerSock := a.ContainerSocket(uid)
+		if strings.Contains(a.Socket, "/") {
+			dir := filepath.Dir(containerSock)
+			if err := client.ExecAsRoot(name, "install", "-d", "-m", "0700", "-o", fmt.Sprint(uid), "-g", fmt.Sprint(gid), dir); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+		}
+
+		if err := hostsock.Forward(client, name, a.Device, filepath.Join(runtimeDir, a.Socket), containerSock, a.EnvKey, a.EnvValue(uid)); err != nil {
+			return fmt.Errorf("failed to forward %s: %w", a.Name, err)
+		}
+	}
+
+	if len(found) == 0 {
+		return ErrNoAudio
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package forward
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectAudio(t *testing.T) {
+	runtimeDir := t.TempDir()
+
+	listen := func(rel string) {
+		t.Helper()
+		path := filepath.Join(runtimeDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skipf("unix sockets unavailable: %v", err)
+		}
+		t.Cleanup(func() { _ = l.Close() })
+	}
+
+	if got := DetectAudio(runtimeDir); len(got) != 0 {
+		t.Errorf("DetectAudio() with no sockets = %+v, want none", got)
+	}
+	if got := DetectAudio(""); len(got) != 0 {
+		t.Errorf("DetectAudio(\"\") = %+v, want none", got)
+	}
+
+	listen("pulse/native")
+	got := DetectAudio(runtimeDir)
+	if len(got) != 1 || got[0].Device != "pulseaudio" {
+		t.Fatalf("DetectAudio() = %+v, want [pulseaudio]", got)
+	}
+
+	listen("pipewire-0")
+	got = DetectAudio(runtimeDir)
+	if len(got) != 2 || got[0].Device != "pipewire" || got[1].Device != "pulseaudio" {
+		t.Errorf("DetectAudio() = %+v, want [pipewire pulseaudio]", got)
+	}
+}
+
+func TestAudioServerEnv(t *testing.T) {
+	want := map[string]string{
+		"pipewire":   "/run/user/1000/pipewire-0",
+		"pulseaudio": "unix:/run/user/1000/pulse/native",
+	}
+	for _, a := range AudioServers {
+		if got := a.EnvValue(1000); got != want[a.Device] {
+			t.Errorf("%s EnvValue(1000) = %q, want %q", a.Device, got, want[a.Device])
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package forward
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// GPGAgentDevice is the name of the proxy device that forwards gpg-agent
+const GPGAgentDevice = "gpg-agent"
+
+// ErrNoGPG is returned when gpg isn't installed on the host
+var ErrNoGPG = errors.New("gpg is not installed on the host")
+
+// GPGAgentSocket returns where the forwarded agent socket appears in the
+// container: the standard gpg-agent socket, so gpg finds it without any
+// configuration
+func GPGAgentSocket(uid int) string {
+	return fmt.Sprintf("/run/user/%d/gnupg/S.gpg-agent", uid)
+}
+
+// HostGPGExtraSocket returns the host gpg-agent's extra socket, which is
+// meant for remote use: it can sign and decrypt, but can't change keys
+func HostGPGExtraSocket() (string, error) {
+	if _, err := exec.LookPath("gpgconf"); err != nil {
+		return "", ErrNoGPG
+	}
+	out, err := exec.Command("gpgconf", "--list-dirs", "agent-extra-socket").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the gpg-agent extra socket: %w", err)
+	}
+	sock := strings.TrimSpace(string(out))
+	if err := checkSocket(sock); err != nil {
+		return "", fmt.Errorf("gpg-agent extra socket %s: %w (is gpg-agent running?)", sock, err)
+	}
+	return sock, nil
+}
+
+// GPGAgent points the container's gpg-agent socket at the host's extra
+// socket, starting the host agent if needed. Like SSHAgent, a stale device is
+// removed when the host has no agent.
+func GPGAgent(client *incus.Client, name string) error {
+	uid := os.Getuid()
+	gid := os.Getgid()
+
+	// The extra socket only exists once the agent is running
+	_ = exec.Command("gpgconf", "--launch", "gpg-agent").Run()
+
+	hostSock, err := HostGPGExtraSocket()
+	if err != nil {
+		if removeErr := hostsock.Remove(client, name, GPGAgentDevice, ""); removeErr != nil {
+			return removeErr
+		}
+		return err
+	}
+
+	// gpg refuses to use a socket directory other users can read, and
+	// /run/user is emptied whenever the container restarts
+	dir := fmt.Sprintf("/run/user/%d/gnupg", uid)
+	if err := client.ExecAsRoot(name, "install", "-d", "-m", "0700", "-o", fmt.Sprint(uid), "-g", fmt.Sprint(gid), dir); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	return hostsock.Forward(client, name, GPGAgentDevice, hostSock, GPGAgentSocket(uid), "", "")
+}
+
+// gpgHomeSetup prepares the container's ~/.gnupg to use the forwarded agent:
+// it must never start an agent of its own, which would take over the socket
+const gpgHomeSetup = `mkdir -p -m 0700 "$HOME/.gnupg" &&
+grep -qx no-autostart "$HOME/.gnupg/gpg.conf" 2>/dev/null || echo no-autostart >> "$HOME/.gnupg/gpg.conf"`
+
+// GPGKeysKey is the instance config key holding a hash of the host public
+// keys and trust last imported into the container
+const GPGKeysKey = "user.igloo.gpg-keys"
+
+// RefreshPublicKeys gives the container a gnupg home holding only the host's
+// public keys and their trust. Secret keys stay on the host, behind the agent.
+// The keys are imported again whenever they or their trust change on the
+// host, such as after adding a signing key. It reports whether it imported
+// them.
+func RefreshPublicKeys(client *incus.Client, name, username string) (bool, error) {
+	keys, trust, err := hostKeyring()
+	if err != nil {
+		return false, err
+	}
+	imported, err := client.GetConfig(name, GPGKeysKey)
+	if err != nil {
+		return false, err
+	}
+	if imported == keyringHash(keys, trust) {
+		return false, nil
+	}
+	return true, importKeyring(client, name, username, keys, trust)
+}
+
+// hostKeyring exports the host's public keys and their trust
+func hostKeyring() (keys, trust []byte, err error) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return nil, nil, ErrNoGPG
+	}
+	keys, err = exec.Command("gpg", "--batch", "--export").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to export public keys: %w", err)
+	}
+	trust, err = exec.Command("gpg", "--batch", "--export-ownertrust").Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to export key trust: %w", err)
+	}
+	return keys, trust, nil
+}
+
+// keyringHash identifies an export of the host keyring. The trust export
+// starts with a comment line holding the time it was made, which is skipped.
+func keyringHash(keys, trust []byte) string {
+	h := sha256.New()
+	h.Write(keys)
+	for line := range bytes.Lines(trust) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			h.Write(line)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// importKeyring imports exported keys and trust into the container's gnupg
+// home and records what was imported
+func importKeyring(client *incus.Client, name, username string, keys, trust []byte) error {
+	if err := client.ExecAsUser(name, username, "/bin/sh", "-c", gpgHomeSetup); err != nil {
+		return fmt.Errorf("failed to set up ~/.gnupg: %w", err)
+	}
+
+	if len(keys) > 0 {
+		if err := client.ExecAsUserWithInput(name, username, bytes.NewReader(keys), "gpg", "--batch", "--quiet", "--import"); err != nil {
+			return fmt.Errorf("failed to import public keys: %w", err)
+		}
+	}
+
+	if len(trust) > 0 {
+		if err := client.ExecAsUserWithInput(name, username, bytes.NewReader(trust), "gpg", "--batch", "--quiet", "--import-ownertrust"); err != nil {
+			return fmt.Errorf("failed to import key trust: %w", err)
+		}
+	}
+
+	return client.SetConfig(name, GPGKeysKey, keyringHash(keys, trust))
+}
+
+// signingTest signs and verifies a message with git's signing key, or gpg's
+// default key if git has none, the same way 'git commit -S' would
+const signingTest = `key=$(git config --get user.signingkey 2>/dev/null)
+signed=$(echo "igloo signing test" | gpg --batch --no-tty --clearsign ${key:+--local-user "$key"}) || exit 1
+echo "$signed" | gpg --batch --no-tty --verify`
+
+// TestSigning checks that commits can be signed inside the container
+func TestSigning(client *incus.Client, name, username string) error {
+	if _, err := client.ExecAsUserOutput(name, username, "/bin/sh", "-c", signingTest); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if msg := lastLine(string(exitErr.Stderr)); msg != "" {
+				return errors.New(msg)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,38 @@
+package forward
+
+import "testing"
+
+func TestGPGAgentSocket(t *testing.T) {
+	if got, want := GPGAgentSocket(1000), "/run/user/1000/gnupg/S.gpg-agent"; got != want {
+		t.Errorf("GPGAgentSocket(1000) = %q, want %q", got, want)
+	}
+}
+
+func TestLastLine(t *testing.T) {
+	tests := map[string]string{
+		"gpg: signing failed: No secret key\n":                                        "gpg: signing failed: No secret key",
+		"gpg: skipped \"ABC\": No secret key\ngpg: signing failed: No secret key\n\n": "gpg: signing failed: No secret key",
+		"": "",
+	}
+	for in, want := range tests {
+		if got := lastLine(in); got != want {
+			t.Errorf("lastLine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestKeyringHash(t *testing.T) {
+	keys := []byte("public key packets")
+	trust := []byte("# List of assigned trustvalues, created Sat Oct 18 10:00:00 2026\n# (Use \"gpg --import-ownertrust\" to restore them)\nABCDEF:6:\n")
+	later := []byte("# List of assigned trustvalues, created Sun Oct 19 09:30:00 2026\n# (Use \"gpg --import-ownertrust\" to restore them)\nABCDEF:6:\n")
+
+	if keyringHash(keys, trust) != keyringHash(keys, later) {
+		t.Error("keyringHash() should ignore when the trust was exported")
+	}
+	if keyringHash(keys, trust) == keyringHash([]byte("more public key packets"), trust) {
+		t.Error("keyringHash() should change with the keys")
+	}
+	if keyringHash(keys, trust) == keyringHash(keys, []byte("ABCDEF:4:\n")) {
+		t.Error("keyringHash() should change with the trust")
+	}
+}
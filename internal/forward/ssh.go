@@ -0,0 +1,69 @@
+// Package forward exposes host sockets, such as ssh-agent and the sound
+// server, inside igloo containers through socket proxy devices.
+package forward
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// SSHAgentDevice is the name of the proxy device that forwards the SSH agent
+const SSHAgentDevice = "ssh-agent"
+
+// sshAuthSockKey is the instance config key that sets SSH_AUTH_SOCK for
+// every shell and command run in the container
+const sshAuthSockKey = "environment.SSH_AUTH_SOCK"
+
+// ErrNoSSHAgent is returned when the host has no SSH agent to forward
+var ErrNoSSHAgent = errors.New("no SSH agent is running on the host (SSH_AUTH_SOCK is not set)")
+
+// SSHAgentSocket returns where the forwarded agent socket appears in the
+// container. It stays the same across login sessions on the host.
+func SSHAgentSocket(uid int) string {
+	return fmt.Sprintf("/run/user/%d/ssh-agent.sock", uid)
+}
+
+// HostSSHAgent returns the host's SSH agent socket
+func HostSSHAgent() (string, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return "", ErrNoSSHAgent
+	}
+	if err := checkSocket(sock); err != nil {
+		return "", fmt.Errorf("SSH agent socket %s: %w", sock, err)
+	}
+	return sock, nil
+}
+
+// SSHAgent points the container's agent socket at the host's current
+// $SSH_AUTH_SOCK. If the host has no agent, the stale device is removed so
+// ssh inside the container doesn't wait on a dead socket, and the reason is
+// returned.
+func SSHAgent(client *incus.Client, name string) error {
+	hostSock, err := HostSSHAgent()
+	if err != nil {
+		if removeErr := hostsock.Remove(client, name, SSHAgentDevice, sshAuthSockKey); removeErr != nil {
+			return removeErr
+		}
+		return err
+	}
+
+	containerSock := SSHAgentSocket(os.Getuid())
+	return hostsock.Forward(client, name, SSHAgentDevice, hostSock, containerSock, sshAuthSockKey, containerSock)
+}
+
+// checkSocket verifies that path is a unix socket
+func checkSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.New("not a socket")
+	}
+	return nil
+}
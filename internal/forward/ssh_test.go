@@ -0,0 +1,63 @@
+package forward
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSSHAgentSocket(t *testing.T) {
+	if got, want := SSHAgentSocket(1000), "/run/user/1000/ssh-agent.sock"; got != want {
+		t.Errorf("SSHAgentSocket(1000) = %q, want %q", got, want)
+	}
+}
+
+func TestHostSSHAgent(t *testing.T) {
+	dir := t.TempDir()
+
+	sock := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer func() { _ = l.Close() }()
+
+	file := filepath.Join(dir, "not-a-socket")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("unset", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", "")
+		if _, err := HostSSHAgent(); !errors.Is(err, ErrNoSSHAgent) {
+			t.Errorf("HostSSHAgent() error = %v, want ErrNoSSHAgent", err)
+		}
+	})
+
+	t.Run("socket", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", sock)
+		got, err := HostSSHAgent()
+		if err != nil {
+			t.Fatalf("HostSSHAgent() error = %v", err)
+		}
+		if got != sock {
+			t.Errorf("HostSSHAgent() = %q, want %q", got, sock)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", filepath.Join(dir, "gone.sock"))
+		if _, err := HostSSHAgent(); err == nil {
+			t.Error("HostSSHAgent() succeeded for a missing socket")
+		}
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", file)
+		if _, err := HostSSHAgent(); err == nil {
+			t.Error("HostSSHAgent() succeeded for a regular file")
+		}
+	})
+}
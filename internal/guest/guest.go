@@ -0,0 +1,71 @@
+// Package guest lets igloo know when it is running inside one of its own
+// containers, and describes that container.
+//
+// Provisioning installs the igloo binary in the container and sets the
+// IGLOO_* variables below in the instance environment, so they are present
+// in every 'igloo enter' shell and 'igloo exec' command.
+package guest
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// BinaryPath is where igloo installs itself in the container
+const BinaryPath = "/usr/local/bin/igloo"
+
+// Environment variables describing the container to programs inside it
+const (
+	EnvContainer   = "IGLOO_CONTAINER"    // Container name; its presence marks igloo as inside
+	EnvProject     = "IGLOO_PROJECT"      // Project name
+	EnvWorkspace   = "IGLOO_WORKSPACE"    // Where the project is mounted in the container
+	EnvHostProject = "IGLOO_HOST_PROJECT" // The project directory on the host
+)
+
+// Info describes the igloo container igloo is running in
+type Info struct {
+	Container   string `json:"container"`
+	Project     string `json:"project"`
+	Workspace   string `json:"workspace"`
+	HostProject string `json:"host_project"`
+}
+
+// Detect returns the container igloo is running in, or nil on the host
+func Detect() *Info {
+	name := os.Getenv(EnvContainer)
+	if name == "" {
+		return nil
+	}
+	return &Info{
+		Container:   name,
+		Project:     os.Getenv(EnvProject),
+		Workspace:   os.Getenv(EnvWorkspace),
+		HostProject: os.Getenv(EnvHostProject),
+	}
+}
+
+// Config returns the instance config keys that set the environment
+// variables for a container
+func (i Info) Config() map[string]string {
+	return map[string]string{
+		"environment." + EnvContainer:   i.Container,
+		"environment." + EnvProject:     i.Project,
+		"environment." + EnvWorkspace:   i.Workspace,
+		"environment." + EnvHostProject: i.HostProject,
+	}
+}
+
+// Install copies the igloo binary at source into the container and sets the
+// environment variables that describe it
+func Install(ctx context.Context, client *incus.Client, info Info, source string) error {
+	if err := client.PushFile(ctx, info.Container, source, BinaryPath, 0755); err != nil {
+		return fmt.Errorf("failed to install %s: %w", BinaryPath, err)
+	}
+	if err := client.SetConfigKeys(info.Container, info.Config()); err != nil {
+		return fmt.Errorf("failed to set igloo environment: %w", err)
+	}
+	return nil
+}
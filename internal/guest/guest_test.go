@@ -0,0 +1,31 @@
+package guest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	t.Setenv(EnvContainer, "")
+	if info := Detect(); info != nil {
+		t.Errorf("Detect() on the host = %+v, want nil", info)
+	}
+
+	want := Info{
+		Container:   "igloo-api",
+		Project:     "api",
+		Workspace:   "/home/dev/workspace/api",
+		HostProject: "/home/dev/work/api",
+	}
+	for key, value := range want.Config() {
+		t.Setenv(key[len("environment."):], value)
+	}
+
+	info := Detect()
+	if info == nil {
+		t.Fatal("Detect() in a container = nil")
+	}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("Detect() = %+v, want %+v", *info, want)
+	}
+}
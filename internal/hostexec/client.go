@@ -0,0 +1,73 @@
+package hostexec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+)
+
+// Device is the name of the proxy device that carries host-exec requests
+const Device = "host-exec"
+
+// ContainerSocket returns where the handler's socket appears in the container
+func ContainerSocket(uid int) string {
+	return hostsock.ContainerPath(uid, "igloo-exec.sock")
+}
+
+// ErrUnavailable is returned when nothing on the host answers requests
+var ErrUnavailable = errors.New("cannot reach the host; host-exec only works inside an igloo with [host_exec] commands, while 'igloo enter' is running on the host")
+
+// Run asks the host handler on sock to run req, copying stdin to the
+// command and its output to stdout and stderr. It returns the command's exit
+// code.
+func Run(sock string, req Request, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return 0, ErrUnavailable
+	}
+	defer func() { _ = conn.Close() }()
+
+	line, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(append(line, '\n')); err != nil {
+		return 0, ErrUnavailable
+	}
+
+	out := &frameWriter{w: conn}
+	go func() {
+		_, _ = io.Copy(out.stream(frameStdin), stdin)
+		_ = out.write(frameStdinEOF, nil)
+	}()
+
+	for {
+		kind, payload, err := readFrame(conn)
+		if err != nil {
+			// The proxy accepts the connection even when the host side is
+			// gone, and then closes it without a reply
+			if errors.Is(err, io.EOF) {
+				return 0, ErrUnavailable
+			}
+			return 0, fmt.Errorf("connection to host lost: %w", err)
+		}
+		switch kind {
+		case frameStdout:
+			_, _ = stdout.Write(payload)
+		case frameStderr:
+			_, _ = stderr.Write(payload)
+		case frameError:
+			return 0, errors.New(string(payload))
+		case frameExit:
+			if len(payload) != 4 {
+				return 0, errors.New("malformed exit status from host")
+			}
+			return int(int32(binary.BigEndian.Uint32(payload))), nil
+		}
+	}
+}
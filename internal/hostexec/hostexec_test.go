@@ -0,0 +1,155 @@
+package hostexec
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/hostsock"
+)
+
+// writeCommand creates an executable script at path
+func writeCommand(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	usrBin := filepath.Join(root, "usr/bin")
+	home := filepath.Join(root, "home/dev")
+	localBin := filepath.Join(home, ".local/bin")
+
+	writeCommand(t, filepath.Join(usrBin, "podman"))
+	writeCommand(t, filepath.Join(usrBin, "flatpak"))
+	// The container can replace anything in the home directory, so these
+	// are never run, whether found there or linked to from elsewhere
+	writeCommand(t, filepath.Join(localBin, "podman"))
+	writeCommand(t, filepath.Join(localBin, "code"))
+	if err := os.Symlink(filepath.Join(localBin, "code"), filepath.Join(usrBin, "code")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{
+		Allow:    []string{"flatpak", "podman", "code"},
+		Path:     localBin + ":" + usrBin,
+		Writable: []string{home},
+		Mappings: []config.PathMapping{
+			{Host: "/home/dev/work/api", Container: "/home/dev/workspace/api"},
+		},
+		Dir: "/home/dev/work/api",
+	}
+
+	tests := []struct {
+		name     string
+		req      Request
+		wantPath string
+		wantDir  string
+		wantErr  bool
+	}{
+		{name: "allowed", req: Request{Args: []string{"podman", "ps"}, Dir: "/home/dev/workspace/api/deploy"},
+			wantPath: filepath.Join(usrBin, "podman"), wantDir: "/home/dev/work/api/deploy"},
+		{name: "unmapped dir", req: Request{Args: []string{"flatpak", "list"}, Dir: "/tmp"},
+			wantPath: filepath.Join(usrBin, "flatpak"), wantDir: "/home/dev/work/api"},
+		{name: "links into the home directory", req: Request{Args: []string{"code"}}, wantErr: true},
+		{name: "not allowed", req: Request{Args: []string{"rm", "-rf", "/"}}, wantErr: true},
+		{name: "path to allowed name", req: Request{Args: []string{"/tmp/evil/podman"}}, wantErr: true},
+		{name: "empty", req: Request{}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, dir, err := h.Check(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if path != tt.wantPath {
+				t.Errorf("Check() path = %q, want %q", path, tt.wantPath)
+			}
+			if dir != tt.wantDir {
+				t.Errorf("Check() dir = %q, want %q", dir, tt.wantDir)
+			}
+		})
+	}
+}
+
+// serve starts a handler on a temporary socket and returns the socket path
+func serve(t *testing.T, h *Handler) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "igloo", "igloo-api-exec.sock")
+	stop, err := hostsock.Listen(sock, h.Serve)
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	t.Cleanup(stop)
+	return sock
+}
+
+func TestRun(t *testing.T) {
+	hostDir := t.TempDir()
+	sock := serve(t, &Handler{
+		Allow:    []string{"sh"},
+		Path:     "/usr/bin:/bin",
+		Mappings: []config.PathMapping{{Host: hostDir, Container: "/home/dev/workspace/api"}},
+	})
+
+	var stdout, stderr bytes.Buffer
+	req := Request{
+		Args: []string{"sh", "-c", "pwd; cat; echo oops >&2; exit 3"},
+		Dir:  "/home/dev/workspace/api",
+	}
+	code, err := Run(sock, req, strings.NewReader("hello\n"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if want := hostDir + "\nhello\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "oops\n")
+	}
+}
+
+func TestRun_Signaled(t *testing.T) {
+	sock := serve(t, &Handler{Allow: []string{"sh"}, Path: "/usr/bin:/bin"})
+
+	code, err := Run(sock, Request{Args: []string{"sh", "-c", "kill -TERM $$"}}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if code != 143 {
+		t.Errorf("exit code = %d, want 143", code)
+	}
+}
+
+func TestRun_NotAllowed(t *testing.T) {
+	sock := serve(t, &Handler{Allow: []string{"podman"}})
+
+	_, err := Run(sock, Request{Args: []string{"sh", "-c", "true"}}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("Run() error = %v, want a not allowed error", err)
+	}
+}
+
+func TestRun_NoHost(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "missing.sock")
+	_, err := Run(sock, Request{Args: []string{"podman"}}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Run() error = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package hostexec runs allowlisted host commands on behalf of an igloo
+// container.
+//
+// 'igloo host-exec' in the container connects to a unix socket proxied to
+// the host, sends a Request, then exchanges frames with the host handler:
+// stdin flows to the host, stdout and stderr flow back, and a final exit
+// frame carries the command's exit code.
+package hostexec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Request asks the host to run a command
+type Request struct {
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"` // Working directory in the container
+}
+
+// Frame types
+const (
+	frameStdin    byte = iota + 1 // Data for the command's stdin
+	frameStdinEOF                 // The container's stdin is closed
+	frameStdout                   // Data the command wrote to stdout
+	frameStderr                   // Data the command wrote to stderr
+	frameExit                     // The command's exit code, as 4 bytes
+	frameError                    // The command could not be run
+)
+
+// maxFrame bounds the payload of a single frame
+const maxFrame = 1 << 20
+
+// frameWriter writes frames to a connection shared by several goroutines
+type frameWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (f *frameWriter) write(kind byte, payload []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var header [5]byte
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := f.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := f.w.Write(payload)
+	return err
+}
+
+// stream returns a writer that sends everything written to it as frames of
+// the given kind
+func (f *frameWriter) stream(kind byte) io.Writer {
+	return streamWriter{f, kind}
+}
+
+type streamWriter struct {
+	f    *frameWriter
+	kind byte
+}
+
+func (s streamWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		n := min(len(p)-written, maxFrame)
+		if err := s.f.write(s.kind, p[written:written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return len(p), nil
+}
+
+// readFrame reads the next frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// exitPayload encodes an exit code
+func exitPayload(code int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(int32(code)))
+}
@@ -0,0 +1,195 @@
+package hostexec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/frostyard/igloo/internal/config"
+)
+
+// Handler runs commands for the container
+type Handler struct {
+	// Allow lists the host commands the container may run, by name
+	Allow []string
+	// Path is the search path for allowed commands, in $PATH form
+	Path string
+	// Writable lists the host directories the container can write to, such
+	// as the project. A command found in one, or linking into one, could
+	// have been replaced by the container and is never run.
+	Writable []string
+	// Mappings translate the container's working directory to the host
+	Mappings []config.PathMapping
+	// Dir is the host working directory for commands run from a container
+	// directory that isn't mounted from the host
+	Dir string
+}
+
+// AllowKey is the instance config key recording the host commands approved
+// on the host. The container can edit [host_exec] in igloo.ini, but not its
+// own instance config.
+const AllowKey = "user.igloo.host-exec"
+
+// Check validates a request and returns the absolute path of the command to
+// run and the host directory to run it in
+func (h *Handler) Check(req Request) (string, string, error) {
+	if len(req.Args) == 0 {
+		return "", "", errors.New("no command given")
+	}
+
+	// Commands are allowed by name only, so a path can't smuggle in a
+	// different binary
+	name := req.Args[0]
+	if strings.Contains(name, "/") || !slices.Contains(h.Allow, name) {
+		if len(h.Allow) == 0 {
+			return "", "", fmt.Errorf("%s is not allowed: no commands are listed under [host_exec]", name)
+		}
+		return "", "", fmt.Errorf("%s is not allowed (allowed: %s)", name, strings.Join(h.Allow, ", "))
+	}
+
+	path, err := h.lookPath(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := h.Dir
+	if req.Dir != "" {
+		if hostDir, ok := config.HostPath(h.Mappings, req.Dir); ok {
+			dir = hostDir
+		}
+	}
+	return path, dir, nil
+}
+
+// lookPath finds an allowed command in the search path, skipping anywhere
+// the container can write to, and returns it with symlinks resolved
+func (h *Handler) lookPath(name string) (string, error) {
+	for _, dir := range filepath.SplitList(h.Path) {
+		if !filepath.IsAbs(dir) || h.writable(dir) {
+			continue
+		}
+		path, err := filepath.EvalSymlinks(filepath.Join(dir, name))
+		if err != nil || h.writable(path) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not installed on the host, outside directories the container can write to", name)
+}
+
+// writable reports whether path lies in a directory the container can
+// write, as given or with symlinks resolved
+func (h *Handler) writable(path string) bool {
+	for _, w := range h.Writable {
+		roots := []string{filepath.Clean(w)}
+		if resolved, err := filepath.EvalSymlinks(w); err == nil {
+			roots = append(roots, resolved)
+		}
+		for _, root := range roots {
+			if path == root || config.ProjectSubdir(root, path) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServeConn runs one request from the container
+func (h *Handler) ServeConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	out := &frameWriter{w: conn}
+	in := bufio.NewReader(conn)
+
+	var req Request
+	line, err := in.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		_ = out.write(frameError, []byte(fmt.Sprintf("bad request: %v", err)))
+		return
+	}
+
+	name, dir, err := h.Check(req)
+	if err != nil {
+		_ = out.write(frameError, []byte(err.Error()))
+		return
+	}
+
+	// The command is killed if the container side goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, req.Args[1:]...)
+	cmd.Args[0] = req.Args[0]
+	cmd.Dir = dir
+	cmd.Stdout = out.stream(frameStdout)
+	cmd.Stderr = out.stream(frameStderr)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		_ = out.write(frameError, []byte(err.Error()))
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		_ = out.write(frameError, []byte(err.Error()))
+		return
+	}
+
+	go func() {
+		for {
+			kind, payload, err := readFrame(in)
+			if err != nil {
+				cancel()
+				return
+			}
+			switch kind {
+			case frameStdin:
+				_, _ = stdin.Write(payload)
+			case frameStdinEOF:
+				_ = stdin.Close()
+			}
+		}
+	}()
+
+	_ = out.write(frameExit, exitPayload(exitCode(cmd.Wait())))
+}
+
+// exitCode returns the shell-style exit status of a finished command
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// Serve runs requests from the container on connections to l, each in the
+// background, until l is closed
+func (h *Handler) Serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go h.ServeConn(conn)
+	}
+}
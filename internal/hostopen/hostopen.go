@@ -0,0 +1,214 @@
+// Package hostopen opens URLs and files from an igloo container on the host.
+//
+// A shim installed in the container as xdg-open and $BROWSER posts each
+// request over a unix socket proxied to the host, where a handler run by
+// 'igloo enter' checks it and passes it to the host's own xdg-open.
+package hostopen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/config"
+)
+
+// Handler serves open requests from the container shim
+type Handler struct {
+	// Schemes are the URL schemes that may be opened
+	Schemes []string
+	// Mappings translate container paths to host paths. Files outside them
+	// don't exist on the host, or aren't the container's to open, and are
+	// refused.
+	Mappings []config.PathMapping
+	// Open opens a URL or host path, normally with the host's xdg-open
+	Open func(target string) error
+	// Forward, if set, makes a port the container listens on on loopback
+	// reachable on the host's loopback, so local URLs such as a pprof web UI
+	// work on the host. Only ports from MinForwardPort up are forwarded.
+	Forward func(port string) error
+}
+
+// SchemesKey is the instance config key recording the URL schemes beyond
+// config.DefaultOpenSchemes approved on the host. The container can edit
+// [open] in igloo.ini, but not its own instance config.
+const SchemesKey = "user.igloo.open-schemes"
+
+// MinForwardPort is the lowest port a local URL can forward. Lower ports are
+// privileged, kept for system services the container mustn't stand in for.
+const MinForwardPort = 1024
+
+// portDevicePrefix names the proxy devices that forward a local URL's port.
+// They only last until 'igloo enter' returns.
+const portDevicePrefix = "open-"
+
+// PortDevice returns the name of the device forwarding port
+func PortDevice(port string) string {
+	return portDevicePrefix + port
+}
+
+// IsPortDevice reports whether a device forwards a local URL's port, and so
+// must not outlive the shell it was added for
+func IsPortDevice(name string) bool {
+	return strings.HasPrefix(name, portDevicePrefix)
+}
+
+// ServeHTTP handles POST /open with the URL or absolute container path in
+// the "target" form value
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/open" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, err := h.Resolve(r.PostFormValue("target"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if h.Forward != nil {
+		if port := loopbackPort(target); port != "" {
+			if n, err := strconv.Atoi(port); err != nil || n < MinForwardPort {
+				http.Error(w, fmt.Sprintf("port %s is not forwarded; only ports from %d up are", port, MinForwardPort), http.StatusForbidden)
+				return
+			}
+			if err := h.Forward(port); err != nil {
+				http.Error(w, fmt.Sprintf("failed to forward port %s: %v", port, err), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	if err := h.Open(target); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Resolve checks a request from the container and returns what to open on
+// the host: a URL with an approved scheme, or the host path of a file in a
+// mounted directory
+func (h *Handler) Resolve(target string) (string, error) {
+	if target == "" {
+		return "", errors.New("nothing to open")
+	}
+
+	if strings.HasPrefix(target, "/") {
+		return h.hostPath(target)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" {
+		return "", fmt.Errorf("%q is neither a URL nor an absolute path", target)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "file" {
+		return h.hostPath(u.Path)
+	}
+	if !slices.Contains(h.Schemes, scheme) {
+		return "", fmt.Errorf("%s: URLs are not allowed (allowed schemes: %s)", scheme, strings.Join(h.Schemes, ", "))
+	}
+	return target, nil
+}
+
+// hostPath translates a container path to the host and checks the file is
+// safe to hand to the host's xdg-open. The container controls what is in the
+// shared directories, so a file must still be inside one once symlinks are
+// followed, and mustn't be something the host would run rather than show:
+// an executable, a .desktop launcher or a special file.
+func (h *Handler) hostPath(path string) (string, error) {
+	hostPath, ok := config.HostPath(h.Mappings, path)
+	if !ok {
+		return "", fmt.Errorf("%s is not in a directory shared with the host", path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(hostPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%s does not exist", path)
+		}
+		return "", err
+	}
+	if !h.shared(resolved) {
+		return "", fmt.Errorf("%s links outside the directories shared with the host", path)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case info.IsDir():
+	case !info.Mode().IsRegular():
+		return "", fmt.Errorf("%s is not a regular file", path)
+	case info.Mode()&0111 != 0:
+		return "", fmt.Errorf("%s is executable; only documents can be opened on the host", path)
+	case strings.EqualFold(filepath.Ext(resolved), ".desktop"):
+		return "", fmt.Errorf("%s is a desktop launcher; only documents can be opened on the host", path)
+	}
+	return resolved, nil
+}
+
+// shared reports whether a host path with symlinks resolved lies in one of
+// the shared directories
+func (h *Handler) shared(path string) bool {
+	for _, m := range h.Mappings {
+		root, err := filepath.EvalSymlinks(m.Host)
+		if err != nil {
+			continue
+		}
+		if path == root || config.ProjectSubdir(root, path) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loopbackPort returns the port of an http(s) URL on the container's
+// loopback interface, or "" for any other target
+func loopbackPort(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		return ""
+	}
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// XDGOpen opens a URL or path with the host's xdg-open, without waiting for
+// the application it starts
+func XDGOpen(target string) error {
+	cmd := exec.Command("xdg-open", target)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run xdg-open: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// Serve answers requests from the container shim on l
+func (h *Handler) Serve(l net.Listener) {
+	_ = http.Serve(l, h)
+}
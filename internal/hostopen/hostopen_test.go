@@ -0,0 +1,208 @@
+package hostopen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frostyard/igloo/internal/config"
+)
+
+func testHandler(project string, opened *[]string, forwarded *[]string) *Handler {
+	return &Handler{
+		Schemes: config.DefaultOpenSchemes,
+		Mappings: []config.PathMapping{
+			{Host: project, Container: "/home/dev/workspace/api"},
+		},
+		Open: func(target string) error {
+			*opened = append(*opened, target)
+			return nil
+		},
+		Forward: func(port string) error {
+			*forwarded = append(*forwarded, port)
+			return nil
+		},
+	}
+}
+
+// testProject creates a project directory holding files the container might
+// ask to open, and returns its path with symlinks resolved
+func testProject(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(root, "api")
+	files := map[string]os.FileMode{
+		"coverage.html":        0644,
+		"docs/a.pdf":           0644,
+		"run.sh":               0755,
+		"app.desktop":          0644,
+		"../outside/notes.txt": 0644,
+	}
+	for name, mode := range files {
+		path := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"escape.html": "../outside/notes.txt",
+		"report.html": "coverage.html",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(project, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return project
+}
+
+func TestResolve(t *testing.T) {
+	project := testProject(t)
+	h := testHandler(project, new([]string), new([]string))
+
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{target: "https://github.com/login/device", want: "https://github.com/login/device"},
+		{target: "HTTP://example.com", want: "HTTP://example.com"},
+		{target: "mailto:dev@example.com", want: "mailto:dev@example.com"},
+		{target: "/home/dev/workspace/api/coverage.html", want: filepath.Join(project, "coverage.html")},
+		{target: "file:///home/dev/workspace/api/docs/a.pdf", want: filepath.Join(project, "docs/a.pdf")},
+		{target: "/home/dev/workspace/api/docs", want: filepath.Join(project, "docs")},
+		{target: "/home/dev/workspace/api/report.html", want: filepath.Join(project, "coverage.html")},
+		{target: "/home/dev/workspace/api/escape.html", wantErr: true},
+		{target: "/home/dev/workspace/api/run.sh", wantErr: true},
+		{target: "/home/dev/workspace/api/app.desktop", wantErr: true},
+		{target: "/home/dev/workspace/api/missing.html", wantErr: true},
+		{target: "/home/dev/host/Downloads/a.pdf", wantErr: true},
+		{target: "/etc/passwd", wantErr: true},
+		{target: "file:///etc/passwd", wantErr: true},
+		{target: "smb://fileserver/share", wantErr: true},
+		{target: "javascript:alert(1)", wantErr: true},
+		{target: "coverage.html", wantErr: true},
+		{target: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := h.Resolve(tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopbackPort(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:8080/ui/":   "8080",
+		"http://127.0.0.1:36123":      "36123",
+		"http://[::1]:9000/":          "9000",
+		"https://localhost/":          "443",
+		"http://localhost":            "80",
+		"https://github.com:8443/":    "",
+		"mailto:dev@localhost":        "",
+		"/home/dev/workspace/api/x.y": "",
+	}
+	for target, want := range tests {
+		if got := loopbackPort(target); got != want {
+			t.Errorf("loopbackPort(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestIsPortDevice(t *testing.T) {
+	if name := PortDevice("8080"); !IsPortDevice(name) {
+		t.Errorf("IsPortDevice(%q) = false, want true", name)
+	}
+	// Port forwards the user added are carried over on rebuild
+	for _, name := range []string{"web", "host-open", "port-8080"} {
+		if IsPortDevice(name) {
+			t.Errorf("IsPortDevice(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	var opened, forwarded []string
+	h := testHandler(testProject(t), &opened, &forwarded)
+
+	post := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/open", strings.NewReader(url.Values{"target": {target}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("http://localhost:6060/ui/"); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d (%s), want %d", rec.Code, rec.Body, http.StatusNoContent)
+	}
+	if rec := post("ftp://example.com"); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "not allowed") {
+		t.Errorf("disallowed scheme: status = %d (%s), want %d", rec.Code, rec.Body, http.StatusForbidden)
+	}
+	if rec := post("https://localhost/"); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "port 443") {
+		t.Errorf("privileged port: status = %d (%s), want %d", rec.Code, rec.Body, http.StatusForbidden)
+	}
+
+	if len(opened) != 1 || opened[0] != "http://localhost:6060/ui/" {
+		t.Errorf("opened = %v, want only the local URL", opened)
+	}
+	if len(forwarded) != 1 || forwarded[0] != "6060" {
+		t.Errorf("forwarded = %v, want [6060]", forwarded)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/open", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestShim(t *testing.T) {
+	dir := t.TempDir()
+	shimPath := filepath.Join(dir, "igloo-open")
+	if err := os.WriteFile(shimPath, []byte(shim), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A fake curl that prints the target it was asked to open
+	fakeCurl := "#!/bin/sh\nfor a; do case $a in target=*) echo \"$a\";; esac; done\n"
+	if err := os.WriteFile(filepath.Join(dir, "curl"), []byte(fakeCurl), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"https://example.com/?q=1": "target=https://example.com/?q=1",
+		"coverage.html":            "target=" + filepath.Join(dir, "coverage.html"),
+		"./docs/../index.html":     "target=" + filepath.Join(dir, "index.html"),
+		"my file:v2.txt":           "target=" + filepath.Join(dir, "my file:v2.txt"),
+	}
+	for arg, want := range tests {
+		cmd := exec.Command("sh", shimPath, arg)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "PATH="+dir+":"+os.Getenv("PATH"))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("shim %q failed: %v\n%s", arg, err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != want {
+			t.Errorf("shim %q sent %q, want %q", arg, got, want)
+		}
+	}
+}
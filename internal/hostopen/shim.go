@@ -0,0 +1,83 @@
+package hostopen
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/frostyard/igloo/internal/hostsock"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// Device is the name of the proxy device that carries open requests
+const Device = "host-open"
+
+// ShimPath is where the shim is installed in the container. It is also
+// linked as xdg-open, ahead of the distro's own in $PATH.
+const ShimPath = "/usr/local/bin/igloo-open"
+
+// xdgOpenPath is the xdg-open link to the shim
+const xdgOpenPath = "/usr/local/bin/xdg-open"
+
+// ContainerSocket returns where the handler's socket appears in the container
+func ContainerSocket(uid int) string {
+	return hostsock.ContainerPath(uid, "igloo-open.sock")
+}
+
+// shim resolves file arguments to absolute paths (following symlinks, so
+// ~/.config links into ~/host work) and posts the request with curl, which
+// every supported image ships
+const shim = `#!/bin/sh
+# Installed by igloo: opens URLs and files on the host.
+sock=/run/user/$(id -u)/igloo-open.sock
+
+if [ $# -ne 1 ]; then
+	echo "usage: ${0##*/} <url or file>" >&2
+	exit 1
+fi
+
+target=$1
+scheme=${target%%:*}
+case $scheme in
+"$target" | "" | *[!A-Za-z0-9+.-]*) target=$(realpath -m -- "$target") ;;
+esac
+
+if ! command -v curl >/dev/null 2>&1; then
+	echo "${0##*/}: curl is needed to open files on the host" >&2
+	exit 1
+fi
+
+curl -sS --fail-with-body --unix-socket "$sock" --data-urlencode "target=$target" http://igloo/open
+status=$?
+if [ $status -ne 0 ] && [ $status -ne 22 ]; then
+	echo "${0##*/}: could not reach the host; is 'igloo enter' running?" >&2
+fi
+exit $status
+`
+
+// Install installs the shim as xdg-open and points $BROWSER at it
+func Install(ctx context.Context, client *incus.Client, name string) error {
+	f, err := os.CreateTemp("", "igloo-open-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.WriteString(shim); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := client.PushFile(ctx, name, f.Name(), ShimPath, 0755); err != nil {
+		return fmt.Errorf("failed to install %s: %w", ShimPath, err)
+	}
+	if err := client.ExecAsRoot(name, "ln", "-sf", ShimPath, xdgOpenPath); err != nil {
+		return fmt.Errorf("failed to link %s: %w", xdgOpenPath, err)
+	}
+	if err := client.SetConfig(name, "environment.BROWSER", ShimPath); err != nil {
+		return fmt.Errorf("failed to set BROWSER: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package hostsock connects host unix sockets to igloo containers through
+// incus socket proxy devices.
+//
+// Some sockets belong to host programs, such as an agent or the session bus,
+// and are forwarded as they are. Others are served by igloo itself for as
+// long as 'igloo enter' runs, like the handlers behind opening URLs and
+// running host commands; those live under $XDG_RUNTIME_DIR/igloo.
+package hostsock
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// HostPath returns the host socket igloo keeps for a container's device
+func HostPath(name, device string) (string, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set")
+	}
+	return filepath.Join(runtimeDir, "igloo", name+"-"+device+".sock"), nil
+}
+
+// ContainerPath returns a socket in the runtime directory of the container
+// user with uid
+func ContainerPath(uid int, file string) string {
+	return fmt.Sprintf("/run/user/%d/%s", uid, file)
+}
+
+// Listen listens on the unix socket sock and hands the listener to serve,
+// run in the background, until the returned stop function is called. If
+// another 'igloo enter' for the same container is already serving the
+// socket, it leaves that one in place and stop does nothing.
+func Listen(sock string, serve func(net.Listener)) (stop func(), err error) {
+	if conn, err := net.Dial("unix", sock); err == nil {
+		_ = conn.Close()
+		return func() {}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sock), 0700); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(sock) // Left behind by an igloo that was killed
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", sock, err)
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+
+	go serve(l)
+	return func() { _ = l.Close() }, nil
+}
+
+// Serve serves a container's device from the host: connections to
+// containerSock in the container reach serve until stop is called. The
+// device stays in place afterwards, and the next 'igloo enter' points it at
+// its own socket.
+func Serve(client *incus.Client, name, device, containerSock string, serve func(net.Listener)) (stop func(), err error) {
+	sock, err := HostPath(name, device)
+	if err != nil {
+		return nil, err
+	}
+	stop, err = Listen(sock, serve)
+	if err != nil {
+		return nil, err
+	}
+	if err := Forward(client, name, device, sock, containerSock, "", ""); err != nil {
+		stop()
+		return nil, err
+	}
+	return stop, nil
+}
+
+// Forward points a proxy device at hostSock, appearing in the container at
+// containerSock and owned by the current user. Host sockets such as agents
+// move between login sessions, so this is re-checked on every enter. When the
+// device changes and envKey is set, that instance config key, such as an
+// environment.* variable pointing clients at the socket, is set to envValue.
+func Forward(client *incus.Client, name, device, hostSock, containerSock, envKey, envValue string) error {
+	uid := os.Getuid()
+	changed, err := client.UpdateProxyDevice(name, device, "unix:"+hostSock, "unix:"+containerSock, uid, os.Getgid())
+	if err != nil {
+		return err
+	}
+	if changed && envKey != "" {
+		if err := client.SetConfig(name, envKey, envValue); err != nil {
+			return fmt.Errorf("failed to set %s: %w", envKey, err)
+		}
+	}
+	return nil
+}
+
+// Remove removes a proxy device and the instance config key that points at
+// it, if present. envKey may be empty if there is no key.
+func Remove(client *incus.Client, name, device, envKey string) error {
+	exists, err := client.DeviceExists(name, device)
+	if err != nil || !exists {
+		return err
+	}
+	if err := client.RemoveDevice(name, device); err != nil {
+		return fmt.Errorf("failed to remove %s device: %w", device, err)
+	}
+	if envKey == "" {
+		return nil
+	}
+	return client.SetConfigKeys(name, map[string]string{envKey: ""})
+}
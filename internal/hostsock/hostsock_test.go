@@ -0,0 +1,70 @@
+package hostsock
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	got, err := HostPath("igloo-api", "host-open")
+	if err != nil {
+		t.Fatalf("HostPath() error: %v", err)
+	}
+	if want := "/run/user/1000/igloo/igloo-api-host-open.sock"; got != want {
+		t.Errorf("HostPath() = %q, want %q", got, want)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if _, err := HostPath("igloo-api", "host-open"); err == nil {
+		t.Error("HostPath() should fail without XDG_RUNTIME_DIR")
+	}
+}
+
+func TestContainerPath(t *testing.T) {
+	if got, want := ContainerPath(1000, "igloo-open.sock"), "/run/user/1000/igloo-open.sock"; got != want {
+		t.Errorf("ContainerPath() = %q, want %q", got, want)
+	}
+}
+
+func TestListen(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "igloo", "igloo-api-open.sock")
+	serve := func(l net.Listener) {
+		_ = http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "ok")
+		}))
+	}
+
+	stop, err := Listen(sock, serve)
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+
+	// A second enter leaves the first one serving
+	stopSecond, err := Listen(sock, serve)
+	if err != nil {
+		t.Fatalf("second Listen() error: %v", err)
+	}
+	stopSecond()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Post("http://igloo/open", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("request after second Listen() failed: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	stop()
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after stop: %v", err)
+	}
+}
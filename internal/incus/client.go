@@ -1,26 +1,118 @@
 package incus
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Client wraps incus CLI commands
-type Client struct{}
+type Client struct {
+	stdout io.Writer
+	debug  io.Writer // Where to log every command, or nil
+	dryRun bool      // Print commands that change state instead of running them
+}
 
 // NewClient creates a new incus client
 func NewClient() *Client {
-	return &Client{}
+	return &Client{stdout: os.Stdout}
+}
+
+// SetOutput sets where progress output from incus commands is written.
+// Interactive sessions and 'igloo exec' always use the terminal.
+func (c *Client) SetOutput(w io.Writer) {
+	c.stdout = w
+}
+
+// SetDebug logs every incus command, with its duration and exit status, to w.
+// A nil writer turns logging off.
+func (c *Client) SetDebug(w io.Writer) {
+	c.debug = w
+}
+
+// SetDryRun makes the client print commands that would change anything
+// instead of running them. Read-only queries still run.
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// command builds an incus command
+func (c *Client) command(args ...string) *exec.Cmd {
+	return exec.Command("incus", args...)
+}
+
+// commandContext builds an incus command that is stopped when ctx is done
+func (c *Client) commandContext(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "incus", args...)
+}
+
+// run runs a command that may change state, honoring dry-run and debug
+func (c *Client) run(cmd *exec.Cmd) error {
+	if c.dryRun {
+		_, _ = fmt.Fprintf(c.stdout, "would run: %s\n", formatCommand(cmd.Args))
+		return nil
+	}
+
+	start := time.Now()
+	err := cmd.Run()
+	c.logCommand(cmd.Args, start, err)
+	return err
+}
+
+// output runs a read-only query and returns its stdout, honoring debug
+func (c *Client) output(cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.Output()
+	c.logCommand(cmd.Args, start, err)
+	return output, err
+}
+
+// logCommand writes a debug line for a finished command
+func (c *Client) logCommand(args []string, start time.Time, err error) {
+	if c.debug == nil {
+		return
+	}
+
+	status := "exit 0"
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		status = fmt.Sprintf("exit %d", exitErr.ExitCode())
+	} else if err != nil {
+		status = err.Error()
+	}
+	_, _ = fmt.Fprintf(c.debug, "[debug] %s (%s, %s)\n", formatCommand(args), time.Since(start).Round(time.Millisecond), status)
+}
+
+// formatCommand renders a command line for logs. Arguments are quoted where
+// needed and multi-line values, like cloud-init user data, are summarized.
+func formatCommand(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if strings.Contains(arg, "\n") {
+			key, _, _ := strings.Cut(arg, "=")
+			parts[i] = fmt.Sprintf("%s=<%d lines>", key, strings.Count(strings.TrimRight(arg, "\n"), "\n")+1)
+			continue
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\$`|&;<>()*?") {
+			arg = strconv.Quote(arg)
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
 }
 
 // InstanceExists checks if an instance with the given name exists
 func (c *Client) InstanceExists(name string) (bool, error) {
-	cmd := exec.Command("incus", "list", "--format=json", name)
-	output, err := cmd.Output()
+	cmd := c.command("list", "--format=json", name)
+	output, err := c.output(cmd)
 	if err != nil {
 		// If incus is not installed or other error
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -37,10 +129,262 @@ func (c *Client) InstanceExists(name string) (bool, error) {
 	return len(instances) > 0, nil
 }
 
+// Instance describes an incus instance and its current resource usage
+type Instance struct {
+	Name        string         `json:"name"`
+	Status      string         `json:"status"`
+	MemoryBytes int64          `json:"memory_bytes"`
+	CPUSeconds  int64          `json:"cpu_seconds"`
+	Processes   int64          `json:"processes"`
+	BaseImage   string         `json:"base_image,omitempty"` // Fingerprint of the image it was created from
+	Owner       Ownership      `json:"owner,omitzero"`       // Igloo ownership tags, if any
+	Provision   ProvisionState `json:"provision,omitzero"`   // How far igloo provisioning got
+
+	// Devices configured directly on the instance, keyed by device name
+	Devices map[string]map[string]string `json:"devices,omitempty"`
+}
+
+// ListInstances returns every instance known to incus
+func (c *Client) ListInstances() ([]Instance, error) {
+	cmd := c.command("list", "--format=json")
+	output, err := c.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("incus command failed: %s", string(exitErr.Stderr))
+		}
+		return nil, err
+	}
+	return parseInstances(output)
+}
+
+// GetInstance returns the named instance, or nil if it doesn't exist
+func (c *Client) GetInstance(name string) (*Instance, error) {
+	cmd := c.command("list", "--format=json", name)
+	output, err := c.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("incus command failed: %s", string(exitErr.Stderr))
+		}
+		return nil, err
+	}
+
+	instances, err := parseInstances(output)
+	if err != nil {
+		return nil, err
+	}
+	// incus list filters by prefix, so look for an exact match
+	for i := range instances {
+		if instances[i].Name == name {
+			return &instances[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// parseInstances extracts instance details from incus list JSON output
+func parseInstances(output []byte) ([]Instance, error) {
+	var raw []struct {
+		Name    string                       `json:"name"`
+		Status  string                       `json:"status"`
+		Config  map[string]string            `json:"config"`
+		Devices map[string]map[string]string `json:"devices"`
+		State   *struct {
+			Memory struct {
+				Usage int64 `json:"usage"`
+			} `json:"memory"`
+			CPU struct {
+				Usage int64 `json:"usage"` // nanoseconds
+			} `json:"cpu"`
+			Processes int64 `json:"processes"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse incus output: %w", err)
+	}
+
+	instances := make([]Instance, 0, len(raw))
+	for _, r := range raw {
+		inst := Instance{
+			Name:      r.Name,
+			Status:    r.Status,
+			BaseImage: r.Config["volatile.base_image"],
+			Owner:     ownershipFromConfig(r.Config),
+			Provision: provisionStateFromConfig(r.Config),
+			Devices:   r.Devices,
+		}
+		if r.State != nil {
+			inst.MemoryBytes = r.State.Memory.Usage
+			inst.CPUSeconds = r.State.CPU.Usage / int64(time.Second)
+			inst.Processes = r.State.Processes
+		}
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+// ServerInfo describes the incus daemon and the host kernel it runs on
+type ServerInfo struct {
+	Auth        string `json:"auth"` // "trusted" when the client may manage the server
+	Environment struct {
+		ServerVersion  string            `json:"server_version"`
+		KernelVersion  string            `json:"kernel_version"`
+		KernelFeatures map[string]string `json:"kernel_features"` // e.g. idmapped_mounts=true
+		Storage        string            `json:"storage"`
+	} `json:"environment"`
+}
+
+// ServerInfo queries the incus daemon for its configuration and environment
+func (c *Client) ServerInfo() (*ServerInfo, error) {
+	var info ServerInfo
+	if err := c.query("/1.0", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Profile describes an incus profile
+type Profile struct {
+	Name    string                       `json:"name"`
+	Devices map[string]map[string]string `json:"devices"`
+}
+
+// GetProfile returns the named profile
+func (c *Client) GetProfile(name string) (*Profile, error) {
+	var profile Profile
+	if err := c.query("/1.0/profiles/"+name, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// query fetches an incus API path and decodes the JSON response into v
+func (c *Client) query(path string, v any) error {
+	cmd := c.command("query", path)
+	output, err := c.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("incus command failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return err
+	}
+	if err := json.Unmarshal(output, v); err != nil {
+		return fmt.Errorf("failed to parse incus output: %w", err)
+	}
+	return nil
+}
+
+// Image describes an image in the local image store
+type Image struct {
+	Fingerprint string `json:"fingerprint"`
+	Alias       string `json:"alias"`  // Remote alias it was downloaded as, e.g. debian/trixie/cloud
+	Cached      bool   `json:"cached"` // True if downloaded automatically when creating an instance
+	Size        int64  `json:"size"`
+}
+
+// ListImages returns every image in the local image store
+func (c *Client) ListImages() ([]Image, error) {
+	cmd := c.command("image", "list", "--format=json")
+	output, err := c.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("incus command failed: %s", string(exitErr.Stderr))
+		}
+		return nil, err
+	}
+
+	var raw []struct {
+		Fingerprint  string `json:"fingerprint"`
+		Cached       bool   `json:"cached"`
+		Size         int64  `json:"size"`
+		UpdateSource *struct {
+			Alias string `json:"alias"`
+		} `json:"update_source"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse incus output: %w", err)
+	}
+
+	images := make([]Image, 0, len(raw))
+	for _, r := range raw {
+		img := Image{Fingerprint: r.Fingerprint, Cached: r.Cached, Size: r.Size}
+		if r.UpdateSource != nil {
+			img.Alias = r.UpdateSource.Alias
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// DeleteImage removes an image from the local image store
+func (c *Client) DeleteImage(fingerprint string) error {
+	cmd := c.command("image", "delete", fingerprint)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// Volume describes a custom storage volume
+type Volume struct {
+	Pool   string    `json:"pool"`
+	Name   string    `json:"name"`
+	UsedBy []string  `json:"used_by"`
+	Owner  Ownership `json:"owner,omitzero"` // Igloo ownership tags, if any
+}
+
+// ListCustomVolumes returns the custom storage volumes in every pool
+func (c *Client) ListCustomVolumes() ([]Volume, error) {
+	cmd := c.command("storage", "volume", "list", "--format=json")
+	output, err := c.output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("incus command failed: %s", string(exitErr.Stderr))
+		}
+		return nil, err
+	}
+
+	return parseVolumes(output)
+}
+
+// parseVolumes extracts the custom volumes from incus storage volume list
+// JSON output
+func parseVolumes(output []byte) ([]Volume, error) {
+	var raw []struct {
+		Pool   string            `json:"pool"`
+		Name   string            `json:"name"`
+		Type   string            `json:"type"`
+		UsedBy []string          `json:"used_by"`
+		Config map[string]string `json:"config"`
+	}
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse incus output: %w", err)
+	}
+
+	var volumes []Volume
+	for _, r := range raw {
+		if r.Type == "custom" {
+			volumes = append(volumes, Volume{
+				Pool:   r.Pool,
+				Name:   r.Name,
+				UsedBy: r.UsedBy,
+				Owner:  ownershipFromConfig(r.Config),
+			})
+		}
+	}
+	return volumes, nil
+}
+
+// DeleteVolume removes a custom storage volume
+func (c *Client) DeleteVolume(pool, name string) error {
+	cmd := c.command("storage", "volume", "delete", pool, name)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
 // IsRunning checks if an instance is currently running
 func (c *Client) IsRunning(name string) (bool, error) {
-	cmd := exec.Command("incus", "list", "--format=json", name)
-	output, err := cmd.Output()
+	cmd := c.command("list", "--format=json", name)
+	output, err := c.output(cmd)
 	if err != nil {
 		return false, err
 	}
@@ -59,35 +403,54 @@ func (c *Client) IsRunning(name string) (bool, error) {
 	return instances[0].Status == "Running", nil
 }
 
-// Create creates a new instance with cloud-init configuration
-func (c *Client) Create(name, image, cloudInit string) error {
+// Create creates a new instance with cloud-init configuration and any
+// additional instance config keys
+func (c *Client) Create(name, image, cloudInit string, config map[string]string) error {
 	args := []string{"init", image, name}
 
 	if cloudInit != "" {
 		args = append(args, "--config", "cloud-init.user-data="+cloudInit)
 	}
+	args = append(args, configArgs(config)...)
 
-	cmd := exec.Command("incus", args...)
-	cmd.Stdout = os.Stdout
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
+	return c.run(cmd)
 }
 
-// Start starts an instance
-func (c *Client) Start(name string) error {
-	cmd := exec.Command("incus", "start", name)
-	cmd.Stdout = os.Stdout
+// Start starts an instance. Cancelling ctx stops waiting for it.
+func (c *Client) Start(ctx context.Context, name string) error {
+	cmd := c.commandContext(ctx, "start", name)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // Stop stops an instance
 func (c *Client) Stop(name string) error {
-	cmd := exec.Command("incus", "stop", name)
-	cmd.Stdout = os.Stdout
+	cmd := c.command("stop", name)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// CreateSnapshot takes a snapshot of an instance
+func (c *Client) CreateSnapshot(name, snapshot string) error {
+	cmd := c.command("snapshot", "create", name, snapshot)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
+}
+
+// CopyInstance copies an instance or snapshot ("name/snapshot") to a new,
+// stopped instance
+func (c *Client) CopyInstance(source, target string) error {
+	cmd := c.command("copy", source, target)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
 }
 
 // Delete deletes an instance
@@ -97,27 +460,27 @@ func (c *Client) Delete(name string, force bool) error {
 		args = append(args, "--force")
 	}
 
-	cmd := exec.Command("incus", args...)
-	cmd.Stdout = os.Stdout
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // AddDiskDevice adds a disk device (mount) to an instance
 func (c *Client) AddDiskDevice(name, deviceName, source, path string) error {
-	cmd := exec.Command("incus", "config", "device", "add", name, deviceName, "disk",
+	cmd := c.command("config", "device", "add", name, deviceName, "disk",
 		"source="+source,
 		"path="+path,
 		"shift=true",
 	)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // AddProxyDevice adds a proxy device for socket passthrough
 func (c *Client) AddProxyDevice(name, deviceName, connect, listen string, uid, gid int) error {
-	cmd := exec.Command("incus", "config", "device", "add", name, deviceName, "proxy",
+	cmd := c.command("config", "device", "add", name, deviceName, "proxy",
 		"connect="+connect,
 		"listen="+listen,
 		"bind=instance",
@@ -126,14 +489,14 @@ func (c *Client) AddProxyDevice(name, deviceName, connect, listen string, uid, g
 		fmt.Sprintf("security.uid=%d", uid),
 		fmt.Sprintf("security.gid=%d", gid),
 	)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // AddSimpleProxyDevice adds a proxy device for file-based sockets with proper permissions
 func (c *Client) AddSimpleProxyDevice(name, deviceName, connect, listen string, uid, gid int) error {
-	cmd := exec.Command("incus", "config", "device", "add", name, deviceName, "proxy",
+	cmd := c.command("config", "device", "add", name, deviceName, "proxy",
 		"connect="+connect,
 		"listen="+listen,
 		"bind=instance",
@@ -141,31 +504,47 @@ func (c *Client) AddSimpleProxyDevice(name, deviceName, connect, listen string,
 		fmt.Sprintf("gid=%d", gid),
 		"mode=0777",
 	)
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// AddDevice adds a device with arbitrary properties, such as one copied from
+// another instance. The "type" property selects the device type.
+func (c *Client) AddDevice(name, deviceName string, device map[string]string) error {
+	props := make(map[string]string, len(device))
+	for k, v := range device {
+		if k != "type" {
+			props[k] = v
+		}
+	}
+	args := append([]string{"config", "device", "add", name, deviceName, device["type"]}, keyValues(props)...)
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // AddGPUDevice adds a GPU device to an instance
 func (c *Client) AddGPUDevice(name string) error {
-	cmd := exec.Command("incus", "config", "device", "add", name, "gpu", "gpu")
-	cmd.Stdout = os.Stdout
+	cmd := c.command("config", "device", "add", name, "gpu", "gpu")
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // RemoveDevice removes a device from an instance
 func (c *Client) RemoveDevice(name, deviceName string) error {
-	cmd := exec.Command("incus", "config", "device", "remove", name, deviceName)
-	cmd.Stdout = os.Stdout
+	cmd := c.command("config", "device", "remove", name, deviceName)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // DeviceExists checks if a device exists on an instance
 func (c *Client) DeviceExists(name, deviceName string) (bool, error) {
-	cmd := exec.Command("incus", "config", "device", "show", name)
-	output, err := cmd.Output()
+	cmd := c.command("config", "device", "show", name)
+	output, err := c.output(cmd)
 	if err != nil {
 		return false, fmt.Errorf("failed to list devices: %w", err)
 	}
@@ -176,14 +555,48 @@ func (c *Client) DeviceExists(name, deviceName string) (bool, error) {
 
 // GetDeviceSource gets the source path of a disk device
 func (c *Client) GetDeviceSource(name, deviceName string) (string, error) {
-	cmd := exec.Command("incus", "config", "device", "get", name, deviceName, "source")
-	output, err := cmd.Output()
+	return c.GetDeviceConfig(name, deviceName, "source")
+}
+
+// GetDeviceConfig gets a single property of a device
+func (c *Client) GetDeviceConfig(name, deviceName, key string) (string, error) {
+	cmd := c.command("config", "device", "get", name, deviceName, key)
+	output, err := c.output(cmd)
 	if err != nil {
-		return "", fmt.Errorf("failed to get device source: %w", err)
+		return "", fmt.Errorf("failed to get device %s: %w", key, err)
 	}
 	return strings.TrimSpace(string(output)), nil
 }
 
+// UpdateProxyDevice makes a socket proxy device connect to the given host
+// socket, adding it or replacing it if it points at an old socket. It reports
+// whether anything changed. Host sockets such as agents move between login
+// sessions, so this is re-checked every time the container is entered.
+func (c *Client) UpdateProxyDevice(name, deviceName, connect, listen string, uid, gid int) (bool, error) {
+	deviceExists, err := c.DeviceExists(name, deviceName)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s device: %w", deviceName, err)
+	}
+
+	if deviceExists {
+		current, err := c.GetDeviceConfig(name, deviceName, "connect")
+		if err != nil {
+			return false, err
+		}
+		if current == connect {
+			return false, nil
+		}
+		if err := c.RemoveDevice(name, deviceName); err != nil {
+			return false, fmt.Errorf("failed to remove old %s device: %w", deviceName, err)
+		}
+	}
+
+	if err := c.AddProxyDevice(name, deviceName, connect, listen, uid, gid); err != nil {
+		return false, fmt.Errorf("failed to add %s device: %w", deviceName, err)
+	}
+	return true, nil
+}
+
 // UpdateXauthority updates the xauthority device mount if the source file has changed
 // This is necessary because XWayland can create new Xauthority files when restarted
 func (c *Client) UpdateXauthority(name string) error {
@@ -235,32 +648,146 @@ func (c *Client) UpdateXauthority(name string) error {
 
 // SetConfig sets a configuration option on an instance
 func (c *Client) SetConfig(name, key, value string) error {
-	cmd := exec.Command("incus", "config", "set", name, key+"="+value)
-	cmd.Stdout = os.Stdout
+	cmd := c.command("config", "set", name, key+"="+value)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
+}
+
+// GetConfig gets a configuration option of an instance, or "" if it isn't set
+func (c *Client) GetConfig(name, key string) (string, error) {
+	cmd := c.command("config", "get", name, key)
+	output, err := c.output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// SetConfigKeys sets several configuration options on an instance at once.
+// Keys with an empty value are unset.
+func (c *Client) SetConfigKeys(name string, config map[string]string) error {
+	args := append([]string{"config", "set", name}, keyValues(config)...)
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
 }
 
 // Exec runs a command in an instance
 func (c *Client) Exec(name string, command ...string) error {
 	args := append([]string{"exec", name, "--"}, command...)
-	cmd := exec.Command("incus", args...)
-	cmd.Stdout = os.Stdout
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
 // ExecAsRoot runs a command in an instance as root
 func (c *Client) ExecAsRoot(name string, command ...string) error {
 	args := append([]string{"exec", name, "--"}, command...)
-	cmd := exec.Command("incus", args...)
-	cmd.Stdout = os.Stdout
+	cmd := c.command(args...)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// ExecAsRootWithEnv runs a command in an instance as root with extra environment variables.
+// Cancelling ctx interrupts the command; incus forwards the signal into the instance.
+func (c *Client) ExecAsRootWithEnv(ctx context.Context, name string, env map[string]string, command ...string) error {
+	args := append([]string{"exec", name}, envArgs(env)...)
+	args = append(args, "--")
+	args = append(args, command...)
+	cmd := c.commandContext(ctx, args...)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 10 * time.Second
+	return c.run(cmd)
+}
+
+// PushFile copies a host file into an instance, creating parent directories
+func (c *Client) PushFile(ctx context.Context, name, source, dest string, mode os.FileMode) error {
+	cmd := c.commandContext(ctx, "file", "push", "--create-dirs",
+		fmt.Sprintf("--mode=%04o", mode.Perm()),
+		source, name+dest,
+	)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// PullFile copies a file out of an instance to a host path
+func (c *Client) PullFile(name, source, dest string) error {
+	cmd := c.command("file", "pull", name+source, dest)
+	cmd.Stdout = c.stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
+}
+
+// configArgs converts instance config keys into sorted --config arguments
+func configArgs(config map[string]string) []string {
+	return keyValueArgs("--config", config)
+}
+
+// envArgs converts an environment map into sorted incus --env arguments
+func envArgs(env map[string]string) []string {
+	return keyValueArgs("--env", env)
+}
+
+// keyValueArgs converts a map into flag KEY=VALUE arguments, sorted by key
+func keyValueArgs(flag string, values map[string]string) []string {
+	args := make([]string, 0, len(values)*2)
+	for _, kv := range keyValues(values) {
+		args = append(args, flag, kv)
+	}
+	return args
+}
+
+// keyValues returns values as key=value strings, sorted by key
+func keyValues(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, k+"="+values[k])
+	}
+	return kvs
 }
 
 // ExecAsUser runs a command in an instance as a specific user
 func (c *Client) ExecAsUser(name, username string, command ...string) error {
+	cmd := c.command(asUserArgs(name, username, command)...)
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// ExecAsUserWithInput runs a command as a specific user, feeding it input on stdin
+func (c *Client) ExecAsUserWithInput(name, username string, input io.Reader, command ...string) error {
+	cmd := c.command(asUserArgs(name, username, command)...)
+	cmd.Stdin = input
+	cmd.Stdout = c.stdout
+	cmd.Stderr = os.Stderr
+	return c.run(cmd)
+}
+
+// ExecAsUserOutput runs a command as a specific user and returns its output.
+// On failure the command's stderr is available from the *exec.ExitError.
+func (c *Client) ExecAsUserOutput(name, username string, command ...string) ([]byte, error) {
+	cmd := c.command(asUserArgs(name, username, command)...)
+	return c.output(cmd)
+}
+
+// asUserArgs returns the incus exec arguments that run command as the
+// mapped host user
+func asUserArgs(name, username string, command []string) []string {
 	uid := os.Getuid()
 	gid := os.Getgid()
 
@@ -272,15 +799,52 @@ func (c *Client) ExecAsUser(name, username string, command ...string) error {
 		"--env", "USER=" + username,
 		"--",
 	}
+	return append(args, command...)
+}
+
+// ExecInteractive runs an interactive shell in an instance, with env added to
+// its environment
+func (c *Client) ExecInteractive(name, username, workDir string, env map[string]string) error {
+	args := userExecArgs(name, username, workDir, env)
+	args = append(args, "--", "/bin/bash", "--login", "-i")
+
+	cmd := c.command(args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// incus handles TTY allocation itself, no special SysProcAttr needed
+	return c.run(cmd)
+}
+
+// ExecCommand runs a non-interactive command in an instance, forwarding stdin,
+// stdout and stderr. The command runs as the mapped host user with the same
+// environment as ExecInteractive, or as root if asRoot is set. A non-zero exit
+// is returned as an *exec.ExitError so callers can propagate the exit code.
+func (c *Client) ExecCommand(name, username, workDir string, env map[string]string, asRoot bool, command ...string) error {
+	var args []string
+	if asRoot {
+		args = []string{"exec", name}
+		if workDir != "" {
+			args = append(args, "--cwd", workDir)
+		}
+		args = append(args, envArgs(env)...)
+	} else {
+		args = userExecArgs(name, username, workDir, env)
+	}
+	args = append(args, "--")
 	args = append(args, command...)
-	cmd := exec.Command("incus", args...)
+
+	cmd := c.command(args...)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return c.run(cmd)
 }
 
-// ExecInteractive runs an interactive shell in an instance
-func (c *Client) ExecInteractive(name, username, workDir string) error {
+// userExecArgs returns the incus exec arguments that run a command as the
+// mapped host user, with the environment igloo sets up for every session
+func userExecArgs(name, username, workDir string, env map[string]string) []string {
 	uid := os.Getuid()
 	gid := os.Getgid()
 
@@ -288,24 +852,27 @@ func (c *Client) ExecInteractive(name, username, workDir string) error {
 		"exec", name,
 		"--user", fmt.Sprintf("%d", uid),
 		"--group", fmt.Sprintf("%d", gid),
-		"--cwd", workDir,
-		"--env", "HOME=/home/" + username,
-		"--env", "USER=" + username,
-		"--env", "XAUTHORITY=/home/" + username + "/.Xauthority",
-		"--", "/bin/bash", "--login", "-i",
 	}
-
-	cmd := exec.Command("incus", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	// incus handles TTY allocation itself, no special SysProcAttr needed
-	return cmd.Run()
+	if workDir != "" {
+		args = append(args, "--cwd", workDir)
+	}
+	args = append(args,
+		"--env", "HOME=/home/"+username,
+		"--env", "USER="+username,
+		"--env", "XAUTHORITY=/home/"+username+"/.Xauthority",
+	)
+	return append(args, envArgs(env)...)
 }
 
-// WaitForCloudInit waits for cloud-init to complete in the instance
-func (c *Client) WaitForCloudInit(name string) error {
+// WaitForCloudInit waits for cloud-init to complete in the instance, or until
+// ctx is cancelled
+func (c *Client) WaitForCloudInit(ctx context.Context, name string) error {
+	if c.dryRun {
+		// Nothing was created, so there is nothing to wait for
+		_, _ = fmt.Fprintf(c.stdout, "would wait for cloud-init in %s\n", name)
+		return nil
+	}
+
 	// Poll for cloud-init status with timeout
 	timeout := time.After(5 * time.Minute)
 	ticker := time.NewTicker(2 * time.Second)
@@ -313,11 +880,13 @@ func (c *Client) WaitForCloudInit(name string) error {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-timeout:
 			return fmt.Errorf("timeout waiting for cloud-init")
 		case <-ticker.C:
-			cmd := exec.Command("incus", "exec", name, "--", "cloud-init", "status")
-			output, err := cmd.Output()
+			cmd := c.commandContext(ctx, "exec", name, "--", "cloud-init", "status")
+			output, err := c.output(cmd)
 			if err != nil {
 				// cloud-init might not be ready yet
 				continue
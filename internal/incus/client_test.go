@@ -1,7 +1,9 @@
 package incus
 
 import (
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -127,3 +129,151 @@ func TestUpdateXauthority_CustomPath(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvArgs(t *testing.T) {
+	env := map[string]string{
+		"IGLOO_USER":    "bjk",
+		"IGLOO_PROJECT": "igloo",
+		"A":             "with spaces",
+	}
+
+	got := envArgs(env)
+	want := []string{
+		"--env", "A=with spaces",
+		"--env", "IGLOO_PROJECT=igloo",
+		"--env", "IGLOO_USER=bjk",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("envArgs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("envArgs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if len(envArgs(nil)) != 0 {
+		t.Error("envArgs(nil) should be empty")
+	}
+}
+
+func TestUserExecArgs(t *testing.T) {
+	args := userExecArgs("igloo-test", "dev", "/home/dev/workspace/proj", map[string]string{"IGLOO_NAME": "api"})
+	joined := strings.Join(args, " ")
+
+	wantParts := []string{
+		"exec igloo-test",
+		fmt.Sprintf("--user %d", os.Getuid()),
+		fmt.Sprintf("--group %d", os.Getgid()),
+		"--cwd /home/dev/workspace/proj",
+		"--env HOME=/home/dev",
+		"--env USER=dev",
+		"--env XAUTHORITY=/home/dev/.Xauthority",
+		"--env IGLOO_NAME=api",
+	}
+	for _, part := range wantParts {
+		if !strings.Contains(joined, part) {
+			t.Errorf("userExecArgs() = %q, missing %q", joined, part)
+		}
+	}
+
+	// An empty workDir leaves the cwd to incus
+	args = userExecArgs("igloo-test", "dev", "", nil)
+	for _, a := range args {
+		if a == "--cwd" {
+			t.Error("userExecArgs() with empty workDir should not pass --cwd")
+		}
+	}
+}
+
+func TestParseInstances(t *testing.T) {
+	output := []byte(`[
+		{
+			"name": "igloo-api",
+			"status": "Running",
+			"config": {
+				"volatile.base_image": "abc123",
+				"user.igloo.project": "/home/dev/api",
+				"user.igloo.user": "dev",
+				"user.igloo.version": "1",
+				"user.igloo.state": "failed",
+				"user.igloo.step": "scripts"
+			},
+			"devices": {
+				"project": {"type": "disk", "source": "/home/dev/api", "path": "/home/dev/workspace/api"}
+			},
+			"state": {
+				"memory": {"usage": 268435456},
+				"cpu": {"usage": 42000000000},
+				"processes": 17
+			}
+		},
+		{
+			"name": "igloo-web",
+			"status": "Stopped",
+			"state": null
+		}
+	]`)
+
+	instances, err := parseInstances(output)
+	if err != nil {
+		t.Fatalf("parseInstances() error = %v", err)
+	}
+
+	want := []Instance{
+		{Name: "igloo-api", Status: "Running", MemoryBytes: 268435456, CPUSeconds: 42, Processes: 17, BaseImage: "abc123",
+			Owner:     Ownership{ProjectPath: "/home/dev/api", User: "dev", Version: "1"},
+			Provision: ProvisionState{State: "failed", Step: "scripts"},
+			Devices: map[string]map[string]string{
+				"project": {"type": "disk", "source": "/home/dev/api", "path": "/home/dev/workspace/api"},
+			}},
+		{Name: "igloo-web", Status: "Stopped"},
+	}
+	if len(instances) != len(want) {
+		t.Fatalf("parseInstances() = %+v, want %+v", instances, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(instances[i], want[i]) {
+			t.Errorf("parseInstances()[%d] = %+v, want %+v", i, instances[i], want[i])
+		}
+	}
+}
+
+func TestParseInstances_Invalid(t *testing.T) {
+	if _, err := parseInstances([]byte("not json")); err == nil {
+		t.Error("parseInstances() should fail for invalid JSON")
+	}
+}
+
+func TestParseVolumes(t *testing.T) {
+	output := []byte(`[
+		{
+			"pool": "default",
+			"name": "igloo-api-cache",
+			"type": "custom",
+			"used_by": ["/1.0/instances/igloo-api"],
+			"config": {
+				"user.igloo.project": "/home/dev/api",
+				"user.igloo.user": "dev",
+				"user.igloo.version": "1"
+			}
+		},
+		{"pool": "default", "name": "scratch", "type": "custom", "used_by": []},
+		{"pool": "default", "name": "igloo-api", "type": "container"}
+	]`)
+
+	volumes, err := parseVolumes(output)
+	if err != nil {
+		t.Fatalf("parseVolumes() error = %v", err)
+	}
+
+	want := []Volume{
+		{Pool: "default", Name: "igloo-api-cache", UsedBy: []string{"/1.0/instances/igloo-api"},
+			Owner: Ownership{ProjectPath: "/home/dev/api", User: "dev", Version: "1"}},
+		{Pool: "default", Name: "scratch", UsedBy: []string{}},
+	}
+	if !reflect.DeepEqual(volumes, want) {
+		t.Errorf("parseVolumes() = %+v, want %+v", volumes, want)
+	}
+}
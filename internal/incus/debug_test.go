@@ -0,0 +1,97 @@
+package incus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeIncus puts an incus script that exits with the given code on PATH
+func fakeIncus(t *testing.T, exitCode string) string {
+	t.Helper()
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	script := "#!/bin/sh\ntouch " + marker + "\nexit " + exitCode + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "incus"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return marker
+}
+
+func TestFormatCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"plain", []string{"incus", "start", "igloo-api"}, "incus start igloo-api"},
+		{"quoted", []string{"incus", "exec", "igloo-api", "--", "/bin/sh", "-c", "echo hi"}, `incus exec igloo-api -- /bin/sh -c "echo hi"`},
+		{"multi-line", []string{"incus", "init", "img", "--config", "cloud-init.user-data=#cloud-config\nusers:\n"}, "incus init img --config cloud-init.user-data=<2 lines>"},
+		{"empty", []string{"incus", ""}, `incus ""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCommand(tt.args); got != tt.want {
+				t.Errorf("formatCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	marker := fakeIncus(t, "0")
+
+	var out bytes.Buffer
+	client := NewClient()
+	client.SetOutput(&out)
+	client.SetDryRun(true)
+
+	if err := client.AddDiskDevice("igloo-api", "project", "/src/api", "/home/dev/workspace/api"); err != nil {
+		t.Fatalf("AddDiskDevice() error = %v", err)
+	}
+	if err := client.WaitForCloudInit(context.Background(), "igloo-api"); err != nil {
+		t.Fatalf("WaitForCloudInit() error = %v", err)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("dry run should not run incus")
+	}
+	want := "would run: incus config device add igloo-api project disk source=/src/api path=/home/dev/workspace/api shift=true\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("dry run output = %q, want prefix %q", out.String(), want)
+	}
+}
+
+func TestDebugLog(t *testing.T) {
+	fakeIncus(t, "3")
+
+	var log bytes.Buffer
+	client := NewClient()
+	client.SetOutput(&bytes.Buffer{})
+	client.SetDebug(&log)
+
+	if err := client.Start(context.Background(), "igloo-api"); err == nil {
+		t.Fatal("Start() should fail when incus exits non-zero")
+	}
+
+	line := log.String()
+	if !strings.HasPrefix(line, "[debug] incus start igloo-api (") || !strings.Contains(line, "exit 3") {
+		t.Errorf("debug log = %q, want command and exit status", line)
+	}
+}
+
+func TestWaitForCloudInit_Cancel(t *testing.T) {
+	fakeIncus(t, "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewClient().WaitForCloudInit(ctx, "igloo-api"); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForCloudInit() with a cancelled context = %v, want context.Canceled", err)
+	}
+}
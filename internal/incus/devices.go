@@ -0,0 +1,19 @@
+package incus
+
+import "strings"
+
+// IsPortForward reports whether a device forwards a network port, as opposed
+// to the unix socket proxies igloo adds for display passthrough
+func IsPortForward(device map[string]string) bool {
+	if device["type"] != "proxy" {
+		return false
+	}
+	listen := device["listen"]
+	return strings.HasPrefix(listen, "tcp:") || strings.HasPrefix(listen, "udp:")
+}
+
+// IsCustomVolume reports whether a device attaches a custom storage volume,
+// as opposed to a host directory or the root disk
+func IsCustomVolume(device map[string]string) bool {
+	return device["type"] == "disk" && device["pool"] != "" && device["path"] != "/"
+}
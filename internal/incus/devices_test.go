@@ -0,0 +1,45 @@
+package incus
+
+import "testing"
+
+func TestIsPortForward(t *testing.T) {
+	tests := []struct {
+		name   string
+		device map[string]string
+		want   bool
+	}{
+		{"tcp proxy", map[string]string{"type": "proxy", "listen": "tcp:0.0.0.0:8080", "connect": "tcp:127.0.0.1:8080"}, true},
+		{"udp proxy", map[string]string{"type": "proxy", "listen": "udp:0.0.0.0:53", "connect": "udp:127.0.0.1:53"}, true},
+		{"wayland socket", map[string]string{"type": "proxy", "listen": "unix:/run/user/1000/wayland-0", "connect": "unix:/run/user/1000/wayland-0"}, false},
+		{"disk", map[string]string{"type": "disk", "source": "/home/dev", "path": "/home/dev/host"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPortForward(tt.device); got != tt.want {
+				t.Errorf("IsPortForward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCustomVolume(t *testing.T) {
+	tests := []struct {
+		name   string
+		device map[string]string
+		want   bool
+	}{
+		{"custom volume", map[string]string{"type": "disk", "pool": "default", "source": "igloo-cache", "path": "/var/cache"}, true},
+		{"root disk", map[string]string{"type": "disk", "pool": "default", "path": "/"}, false},
+		{"host directory", map[string]string{"type": "disk", "source": "/home/dev", "path": "/home/dev/host", "shift": "true"}, false},
+		{"gpu", map[string]string{"type": "gpu"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCustomVolume(tt.device); got != tt.want {
+				t.Errorf("IsCustomVolume() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
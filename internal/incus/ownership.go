@@ -0,0 +1,58 @@
+package incus
+
+// Instance config keys igloo uses to tag the containers it creates
+const (
+	OwnerProjectKey = "user.igloo.project" // Absolute host path of the project
+	OwnerUserKey    = "user.igloo.user"    // Host user that created the container
+	OwnerVersionKey = "user.igloo.version" // Version of this tagging scheme
+)
+
+// OwnershipVersion is the current value written to OwnerVersionKey
+const OwnershipVersion = "1"
+
+// Ownership records which project and host user an instance belongs to
+type Ownership struct {
+	ProjectPath string `json:"project_path,omitempty"`
+	User        string `json:"user,omitempty"`
+	Version     string `json:"version,omitempty"`
+}
+
+// NewOwnership returns the ownership tags for a project created by user
+func NewOwnership(projectPath, user string) Ownership {
+	return Ownership{
+		ProjectPath: projectPath,
+		User:        user,
+		Version:     OwnershipVersion,
+	}
+}
+
+// ownershipFromConfig reads the ownership tags from an instance's or
+// volume's config
+func ownershipFromConfig(config map[string]string) Ownership {
+	return Ownership{
+		ProjectPath: config[OwnerProjectKey],
+		User:        config[OwnerUserKey],
+		Version:     config[OwnerVersionKey],
+	}
+}
+
+// Config returns the instance config keys for the ownership tags
+func (o Ownership) Config() map[string]string {
+	return map[string]string{
+		OwnerProjectKey: o.ProjectPath,
+		OwnerUserKey:    o.User,
+		OwnerVersionKey: o.Version,
+	}
+}
+
+// Tagged reports whether the instance carries igloo ownership tags.
+// Containers created before tagging, or outside igloo, are untagged.
+func (o Ownership) Tagged() bool {
+	return o.ProjectPath != ""
+}
+
+// Matches reports whether the instance is tagged as belonging to the given
+// project and user
+func (o Ownership) Matches(projectPath, user string) bool {
+	return o.Tagged() && o.ProjectPath == projectPath && o.User == user
+}
@@ -0,0 +1,64 @@
+package incus
+
+import (
+	"testing"
+)
+
+func TestOwnershipMatches(t *testing.T) {
+	owner := NewOwnership("/home/dev/work/api", "dev")
+
+	tests := []struct {
+		name        string
+		owner       Ownership
+		projectPath string
+		user        string
+		want        bool
+	}{
+		{"same project and user", owner, "/home/dev/work/api", "dev", true},
+		{"different project", owner, "/home/dev/oss/api", "dev", false},
+		{"different user", owner, "/home/dev/work/api", "alice", false},
+		{"untagged", Ownership{}, "/home/dev/work/api", "dev", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.owner.Matches(tt.projectPath, tt.user); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.projectPath, tt.user, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnershipConfigRoundTrip(t *testing.T) {
+	owner := NewOwnership("/home/dev/api", "dev")
+	if owner.Version != OwnershipVersion {
+		t.Errorf("NewOwnership().Version = %q, want %q", owner.Version, OwnershipVersion)
+	}
+
+	got := ownershipFromConfig(owner.Config())
+	if got != owner {
+		t.Errorf("ownershipFromConfig(Config()) = %+v, want %+v", got, owner)
+	}
+
+	if ownershipFromConfig(map[string]string{"volatile.base_image": "abc"}).Tagged() {
+		t.Error("instance without user.igloo.* keys should be untagged")
+	}
+}
+
+func TestConfigArgs(t *testing.T) {
+	got := configArgs(NewOwnership("/home/dev/api", "dev").Config())
+	want := []string{
+		"--config", "user.igloo.project=/home/dev/api",
+		"--config", "user.igloo.user=dev",
+		"--config", "user.igloo.version=1",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("configArgs() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("configArgs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package incus
+
+// Instance config keys igloo uses to track provisioning progress
+const (
+	StateKey = "user.igloo.state" // One of the State* values
+	StepKey  = "user.igloo.step"  // The provisioning step in progress, or that failed
+)
+
+// Provisioning states recorded in StateKey
+const (
+	StateProvisioning = "provisioning" // Provisioning is running, or was interrupted
+	StateFailed       = "failed"       // A provisioning step failed
+	StateReady        = "ready"        // Provisioning completed
+)
+
+// ProvisionState records how far provisioning of an instance got
+type ProvisionState struct {
+	State string `json:"state,omitempty"`
+	Step  string `json:"step,omitempty"`
+}
+
+// provisionStateFromConfig reads the provisioning state from an instance's config
+func provisionStateFromConfig(config map[string]string) ProvisionState {
+	return ProvisionState{
+		State: config[StateKey],
+		Step:  config[StepKey],
+	}
+}
+
+// Config returns the instance config keys for the provisioning state. An
+// empty step clears the step key.
+func (s ProvisionState) Config() map[string]string {
+	return map[string]string{
+		StateKey: s.State,
+		StepKey:  s.Step,
+	}
+}
+
+// Incomplete reports whether provisioning started but never finished.
+// Instances created before igloo tracked state have no state and are
+// considered complete.
+func (s ProvisionState) Incomplete() bool {
+	return s.State == StateProvisioning || s.State == StateFailed
+}
@@ -0,0 +1,32 @@
+package incus
+
+import (
+	"testing"
+)
+
+func TestProvisionStateIncomplete(t *testing.T) {
+	tests := []struct {
+		state string
+		want  bool
+	}{
+		{StateProvisioning, true},
+		{StateFailed, true},
+		{StateReady, false},
+		{"", false}, // Created before state tracking
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			if got := (ProvisionState{State: tt.state}).Incomplete(); got != tt.want {
+				t.Errorf("Incomplete() for state %q = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisionStateConfigRoundTrip(t *testing.T) {
+	state := ProvisionState{State: StateFailed, Step: "script:02-tools.sh"}
+	if got := provisionStateFromConfig(state.Config()); got != state {
+		t.Errorf("provisionStateFromConfig(Config()) = %+v, want %+v", got, state)
+	}
+}
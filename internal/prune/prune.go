@@ -0,0 +1,170 @@
+package prune
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// VolumePrefix is the name prefix of storage volumes that belong to igloo
+const VolumePrefix = "igloo-"
+
+// State is a snapshot of everything prune cross-references
+type State struct {
+	Registry  []config.RegistryEntry // Containers igloo has provisioned
+	Hashed    []string               // Containers with a stored config hash
+	Instances []incus.Instance       // Instances incus knows about
+	Images    []incus.Image          // Images in the local image store
+	Volumes   []incus.Volume         // Custom storage volumes
+	User      string                 // The user running prune
+
+	// ProjectExists reports whether a project directory still has an igloo
+	// config, or an error if that can't be told
+	ProjectExists func(path string) (bool, error)
+}
+
+// Orphan is a container whose project directory is gone
+type Orphan struct {
+	Name        string `json:"name"`
+	ProjectPath string `json:"project_path"`
+}
+
+// Skipped is a container prune left alone because its project directory
+// couldn't be checked
+type Skipped struct {
+	Name        string `json:"name"`
+	ProjectPath string `json:"project_path"`
+	Error       string `json:"error"`
+}
+
+// Report lists everything prune would remove
+type Report struct {
+	Orphans    []Orphan       `json:"orphans"`     // Containers whose project no longer exists
+	StaleState []string       `json:"stale_state"` // Containers with hash/registry files but no instance
+	Images     []incus.Image  `json:"images"`      // Cached igloo images no instance uses
+	Volumes    []incus.Volume `json:"volumes"`     // igloo volumes not attached to any instance
+	Skipped    []Skipped      `json:"skipped"`     // Containers whose project couldn't be checked
+}
+
+// Empty reports whether there is nothing to prune
+func (r *Report) Empty() bool {
+	return len(r.Orphans) == 0 && len(r.StaleState) == 0 && len(r.Images) == 0 && len(r.Volumes) == 0
+}
+
+// Analyze cross-references the registry, the filesystem and incus
+func Analyze(s State) *Report {
+	report := &Report{}
+
+	live := make(map[string]incus.Instance, len(s.Instances))
+	usedImages := make(map[string]bool)
+	for _, inst := range s.Instances {
+		live[inst.Name] = inst
+		if inst.BaseImage != "" {
+			usedImages[inst.BaseImage] = true
+		}
+	}
+
+	// checkProject records a live container as an orphan if its project is
+	// gone, or as skipped if that can't be told
+	checked := make(map[string]bool)
+	checkProject := func(name, projectPath string) {
+		checked[name] = true
+		exists, err := s.ProjectExists(projectPath)
+		if err != nil {
+			report.Skipped = append(report.Skipped, Skipped{Name: name, ProjectPath: projectPath, Error: err.Error()})
+		} else if !exists {
+			report.Orphans = append(report.Orphans, Orphan{Name: name, ProjectPath: projectPath})
+		}
+	}
+
+	// Every container igloo has state for, registered or hash-only
+	known := make(map[string]bool)
+	for _, name := range s.Hashed {
+		known[name] = true
+	}
+
+	iglooAliases := make(map[string]bool)
+	for _, reg := range s.Registry {
+		known[reg.Name] = true
+		iglooAliases[imageAlias(reg.Image)] = true
+
+		inst, ok := live[reg.Name]
+		if !ok || reg.ProjectPath == "" {
+			continue
+		}
+		// The name may since have been taken by another project or user;
+		// untagged containers predate the tags and go by the registry
+		if inst.Owner.Tagged() && !inst.Owner.Matches(reg.ProjectPath, s.User) {
+			continue
+		}
+		checkProject(reg.Name, reg.ProjectPath)
+	}
+
+	// Containers whose provisioning failed or stopped partway are tagged but
+	// never registered
+	for _, inst := range s.Instances {
+		if checked[inst.Name] || !inst.Owner.Tagged() || inst.Owner.User != s.User {
+			continue
+		}
+		checkProject(inst.Name, inst.Owner.ProjectPath)
+	}
+	sort.Slice(report.Orphans, func(i, j int) bool { return report.Orphans[i].Name < report.Orphans[j].Name })
+
+	for name := range known {
+		if _, ok := live[name]; !ok {
+			report.StaleState = append(report.StaleState, name)
+		}
+	}
+	sort.Strings(report.StaleState)
+
+	// Only consider images igloo downloaded on its own, for images igloo uses
+	for _, img := range s.Images {
+		if img.Cached && iglooAliases[img.Alias] && !usedImages[img.Fingerprint] {
+			report.Images = append(report.Images, img)
+		}
+	}
+
+	// A volume only counts as this user's when it is tagged, not just named,
+	// so one someone else happens to call igloo-* is never removed. It also
+	// has to outlive its project, since igloo never attaches volumes itself.
+	for _, vol := range s.Volumes {
+		if !strings.HasPrefix(vol.Name, VolumePrefix) || len(vol.UsedBy) != 0 {
+			continue
+		}
+		if !vol.Owner.Tagged() || vol.Owner.User != s.User {
+			continue
+		}
+		if exists, err := s.ProjectExists(vol.Owner.ProjectPath); err == nil && !exists {
+			report.Volumes = append(report.Volumes, vol)
+		}
+	}
+
+	return report
+}
+
+// ProjectExists reports whether path still contains an igloo configuration.
+// Only a config that is known to be gone counts as missing; any other error,
+// such as an unmounted or unreadable directory, is returned.
+func ProjectExists(path string) (bool, error) {
+	_, err := os.Stat(filepath.Join(path, config.ConfigPath()))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// imageAlias converts an image reference like images:debian/trixie/cloud
+// into the alias incus records for it (debian/trixie/cloud)
+func imageAlias(image string) string {
+	if idx := strings.Index(image, ":"); idx >= 0 {
+		return image[idx+1:]
+	}
+	return image
+}
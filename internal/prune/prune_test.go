@@ -0,0 +1,156 @@
+package prune
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+func TestAnalyze(t *testing.T) {
+	existing := map[string]bool{"/work/api": true}
+	owner := incus.NewOwnership("/work/old", "tester")
+
+	state := State{
+		Registry: []config.RegistryEntry{
+			{Name: "igloo-api", ProjectPath: "/work/api", Image: "images:debian/trixie/cloud"},
+			{Name: "igloo-old", ProjectPath: "/work/old", Image: "images:debian/trixie/cloud"},
+			{Name: "igloo-gone", ProjectPath: "/work/gone", Image: "images:ubuntu/noble/cloud"},
+			{Name: "igloo-usb", ProjectPath: "/media/usb/web", Image: "images:debian/trixie/cloud"},
+			{Name: "igloo-reused", ProjectPath: "/work/moved", Image: "images:debian/trixie/cloud"},
+		},
+		Hashed: []string{"igloo-api", "igloo-legacy"},
+		Instances: []incus.Instance{
+			{Name: "igloo-api", Status: "Running", BaseImage: "trixie1"},
+			{Name: "igloo-old", Status: "Stopped", BaseImage: "trixie1"},
+			{Name: "igloo-usb", Status: "Stopped", BaseImage: "trixie1"},
+			{Name: "unrelated", Status: "Running", BaseImage: "alpine1"},
+			// Provisioning failed before it was registered
+			{Name: "igloo-half", Status: "Stopped", Owner: incus.NewOwnership("/work/half", "tester")},
+			{Name: "igloo-theirs", Status: "Running", Owner: incus.NewOwnership("/work/theirs", "someone")},
+			// Registered for /work/moved, but the name now belongs to /work/api
+			{Name: "igloo-reused", Status: "Running", Owner: incus.NewOwnership("/work/api", "tester")},
+		},
+		Images: []incus.Image{
+			{Fingerprint: "trixie1", Alias: "debian/trixie/cloud", Cached: true},
+			{Fingerprint: "trixie0", Alias: "debian/trixie/cloud", Cached: true},
+			{Fingerprint: "noble0", Alias: "ubuntu/noble/cloud", Cached: false},
+			{Fingerprint: "alpine0", Alias: "alpine/edge", Cached: true},
+		},
+		Volumes: []incus.Volume{
+			{Pool: "default", Name: "igloo-api-cache", UsedBy: []string{"/1.0/instances/igloo-api"}, Owner: owner},
+			{Pool: "default", Name: "igloo-old-cache", Owner: owner},
+			{Pool: "default", Name: "igloo-untagged"},
+			{Pool: "default", Name: "igloo-theirs-cache", Owner: incus.NewOwnership("/work/old", "someone")},
+			{Pool: "default", Name: "igloo-live-cache", Owner: incus.NewOwnership("/work/api", "tester")},
+			{Pool: "default", Name: "scratch", Owner: owner},
+		},
+		User: "tester",
+		ProjectExists: func(path string) (bool, error) {
+			if path == "/media/usb/web" {
+				return false, errors.New("input/output error")
+			}
+			return existing[path], nil
+		},
+	}
+
+	report := Analyze(state)
+
+	wantOrphans := []Orphan{
+		{Name: "igloo-half", ProjectPath: "/work/half"},
+		{Name: "igloo-old", ProjectPath: "/work/old"},
+	}
+	if len(report.Orphans) != len(wantOrphans) {
+		t.Fatalf("Orphans = %+v, want %+v", report.Orphans, wantOrphans)
+	}
+	for i := range wantOrphans {
+		if report.Orphans[i] != wantOrphans[i] {
+			t.Errorf("Orphans[%d] = %+v, want %+v", i, report.Orphans[i], wantOrphans[i])
+		}
+	}
+
+	wantStale := []string{"igloo-gone", "igloo-legacy"}
+	if len(report.StaleState) != len(wantStale) {
+		t.Fatalf("StaleState = %v, want %v", report.StaleState, wantStale)
+	}
+	for i := range wantStale {
+		if report.StaleState[i] != wantStale[i] {
+			t.Errorf("StaleState[%d] = %q, want %q", i, report.StaleState[i], wantStale[i])
+		}
+	}
+
+	// Only cached, unused images for distros igloo uses
+	if len(report.Images) != 1 || report.Images[0].Fingerprint != "trixie0" {
+		t.Errorf("Images = %+v, want [trixie0]", report.Images)
+	}
+
+	if len(report.Volumes) != 1 || report.Volumes[0].Name != "igloo-old-cache" {
+		t.Errorf("Volumes = %+v, want [igloo-old-cache]", report.Volumes)
+	}
+
+	if len(report.Skipped) != 1 || report.Skipped[0].Name != "igloo-usb" || report.Skipped[0].Error != "input/output error" {
+		t.Errorf("Skipped = %+v, want [igloo-usb]", report.Skipped)
+	}
+
+	if report.Empty() {
+		t.Error("Empty() = true, want false")
+	}
+}
+
+func TestAnalyze_Nothing(t *testing.T) {
+	report := Analyze(State{
+		Registry:      []config.RegistryEntry{{Name: "igloo-api", ProjectPath: "/work/api"}},
+		Instances:     []incus.Instance{{Name: "igloo-api", Status: "Running"}},
+		ProjectExists: func(string) (bool, error) { return true, nil },
+	})
+
+	if !report.Empty() {
+		t.Errorf("Analyze() = %+v, want empty report", report)
+	}
+}
+
+func TestProjectExists(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if exists, err := ProjectExists(tmpDir); exists || err != nil {
+		t.Errorf("ProjectExists() = %v, %v for directory without .igloo, want false, nil", exists, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, config.ConfigDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, config.ConfigPath()), []byte("[container]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := ProjectExists(tmpDir); !exists || err != nil {
+		t.Errorf("ProjectExists() = %v, %v for directory with .igloo/igloo.ini, want true, nil", exists, err)
+	}
+	if exists, err := ProjectExists(filepath.Join(tmpDir, "missing")); exists || err != nil {
+		t.Errorf("ProjectExists() = %v, %v for missing directory, want false, nil", exists, err)
+	}
+
+	// A path that can't be checked is neither there nor gone
+	file := filepath.Join(tmpDir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ProjectExists(file); err == nil {
+		t.Error("ProjectExists() should fail when the project path is not a directory")
+	}
+}
+
+func TestImageAlias(t *testing.T) {
+	tests := map[string]string{
+		"images:debian/trixie/cloud": "debian/trixie/cloud",
+		"ubuntu/noble":               "ubuntu/noble",
+	}
+	for in, want := range tests {
+		if got := imageAlias(in); got != want {
+			t.Errorf("imageAlias(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
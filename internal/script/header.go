@@ -0,0 +1,86 @@
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headerDirective is the comment prefix for per-script settings, e.g.:
+//
+//	# igloo: timeout=10m retries=2
+const headerDirective = "igloo:"
+
+// Settings holds per-script execution settings
+type Settings struct {
+	Timeout time.Duration
+	Retries int
+}
+
+// ParseHeaderFile reads per-script settings from a script file on the host
+func ParseHeaderFile(path string, defaults Settings) (Settings, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return defaults, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return ParseHeader(f, defaults)
+}
+
+// ParseHeader reads "# igloo: key=value" directives from the leading comment
+// block of a script. Values not set in the header keep their defaults.
+func ParseHeader(r io.Reader, defaults Settings) (Settings, error) {
+	settings := defaults
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// Stop at the first line that isn't a comment
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if !strings.HasPrefix(line, headerDirective) {
+			continue
+		}
+
+		for _, field := range strings.Fields(strings.TrimPrefix(line, headerDirective)) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return defaults, fmt.Errorf("invalid igloo directive %q (expected key=value)", field)
+			}
+
+			switch key {
+			case "timeout":
+				d, err := time.ParseDuration(value)
+				if err != nil {
+					return defaults, fmt.Errorf("invalid timeout %q: %w", value, err)
+				}
+				settings.Timeout = d
+			case "retries":
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 0 {
+					return defaults, fmt.Errorf("invalid retries %q", value)
+				}
+				settings.Retries = n
+			default:
+				return defaults, fmt.Errorf("unknown igloo directive %q", key)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return defaults, err
+	}
+
+	return settings, nil
+}
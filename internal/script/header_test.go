@@ -0,0 +1,82 @@
+package script
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHeader(t *testing.T) {
+	defaults := Settings{Timeout: 5 * time.Minute, Retries: 1}
+
+	tests := []struct {
+		name    string
+		content string
+		want    Settings
+		wantErr bool
+	}{
+		{
+			name:    "no directives keeps defaults",
+			content: "#!/bin/bash\n# Install things\napt-get update\n",
+			want:    defaults,
+		},
+		{
+			name:    "timeout and retries",
+			content: "#!/bin/bash\n# igloo: timeout=10m retries=3\napt-get update\n",
+			want:    Settings{Timeout: 10 * time.Minute, Retries: 3},
+		},
+		{
+			name:    "directive without space",
+			content: "#!/bin/sh\n#igloo: retries=0\n",
+			want:    Settings{Timeout: 5 * time.Minute, Retries: 0},
+		},
+		{
+			name:    "directives on separate lines",
+			content: "#!/bin/bash\n\n# igloo: timeout=30s\n# igloo: retries=2\n",
+			want:    Settings{Timeout: 30 * time.Second, Retries: 2},
+		},
+		{
+			name:    "directive after code is ignored",
+			content: "#!/bin/bash\necho hi\n# igloo: timeout=1s\n",
+			want:    defaults,
+		},
+		{
+			name:    "invalid timeout",
+			content: "# igloo: timeout=soon\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative retries",
+			content: "# igloo: retries=-1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			content: "# igloo: parallel=true\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			content: "# igloo: timeout\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHeader(strings.NewReader(tt.content), defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHeaderFile_NotFound(t *testing.T) {
+	if _, err := ParseHeaderFile("/nonexistent/script.sh", Settings{}); err == nil {
+		t.Error("ParseHeaderFile() should fail for nonexistent file")
+	}
+}
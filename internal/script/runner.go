@@ -1,15 +1,35 @@
 package script
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/frostyard/igloo/internal/config"
 	"github.com/frostyard/igloo/internal/incus"
 )
 
+// IGLOO_PHASE values passed to scripts
+const (
+	PhaseProvision = "provision" // Init scripts, run once while provisioning
+	PhaseStart     = "start"     // On-start hooks, run each time the container starts
+)
+
+// LibraryDir is where included library scripts are copied inside the container
+const LibraryDir = "/var/lib/igloo/scripts"
+
+// retryDelay is the base delay between script retries
+const retryDelay = 2 * time.Second
+
 // Runner handles script execution in incus instances
 type Runner struct {
 	client      *incus.Client
@@ -17,6 +37,23 @@ type Runner struct {
 	username    string
 	projectName string
 	projectDir  string
+	distro      string
+	release     string
+	phase       string
+	extraEnv    map[string]string
+	options     config.ScriptsConfig
+	workspace   string
+	progress    Progress
+	startAt     string
+	only        []string
+	local       bool
+	output      io.Writer
+}
+
+// Progress is notified as each script starts and finishes
+type Progress interface {
+	ScriptStarted(s Script)
+	ScriptFinished(r Result)
 }
 
 // NewRunner creates a new script runner
@@ -27,88 +64,387 @@ func NewRunner(client *incus.Client, instance, username, projectName, projectDir
 		username:    username,
 		projectName: projectName,
 		projectDir:  projectDir,
+		phase:       PhaseProvision,
 	}
 }
 
-// RunScripts executes all scripts in the .igloo/scripts directory in lexicographical order
-func (r *Runner) RunScripts() error {
-	scriptsPath := filepath.Join(r.projectDir, config.ScriptsPath())
+// SetImage records the distro and release scripts are running on
+func (r *Runner) SetImage(image string) {
+	r.distro, r.release = config.ParseImage(image)
+}
+
+// SetPhase sets the IGLOO_PHASE value passed to scripts. The start phase runs
+// the on-start hooks instead of the init scripts.
+func (r *Runner) SetPhase(phase string) {
+	r.phase = phase
+}
 
-	// Check if scripts directory exists on the host
-	entries, err := os.ReadDir(scriptsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// No scripts directory - nothing to do
-			return nil
-		}
-		return fmt.Errorf("failed to read scripts directory: %w", err)
+// SetEnv sets user-defined environment variables passed to scripts.
+// Standard IGLOO_* variables take precedence over these.
+func (r *Runner) SetEnv(env map[string]string) {
+	r.extraEnv = env
+}
+
+// SetOptions sets the default timeout, retries and keep-going behavior
+func (r *Runner) SetOptions(opts config.ScriptsConfig) {
+	r.options = opts
+}
+
+// SetProgress sets a receiver for per-script progress notifications
+func (r *Runner) SetProgress(p Progress) {
+	r.progress = p
+}
+
+// SetStartAt makes RunScripts skip scripts that sort before name, to resume
+// after a failed script
+func (r *Runner) SetStartAt(name string) {
+	r.startAt = name
+}
+
+// SetOnly limits RunScripts to the named scripts. A name matches a script
+// with the same file name, or the same name plus ".sh".
+func (r *Runner) SetOnly(names []string) {
+	r.only = names
+}
+
+// SetLocal runs scripts directly instead of through incus, for igloo running
+// inside the container. Scripts still run as root, through sudo if needed.
+func (r *Runner) SetLocal(local bool) {
+	r.local = local
+}
+
+// SetOutput sets where the output of locally run scripts goes; it defaults
+// to stdout. Scripts run through incus follow the client's output instead.
+func (r *Runner) SetOutput(w io.Writer) {
+	r.output = w
+}
+
+// SetWorkspacePath overrides where the project is mounted in the container
+func (r *Runner) SetWorkspacePath(path string) {
+	r.workspace = path
+}
+
+// WorkspacePath returns the path where the project is mounted in the container.
+// Defaults to /home/$USER/workspace/$projectName.
+func (r *Runner) WorkspacePath() string {
+	if r.workspace != "" {
+		return r.workspace
+	}
+	return fmt.Sprintf("/home/%s/workspace/%s", r.username, r.projectName)
+}
+
+// Env returns the environment variables passed to every script
+func (r *Runner) Env() map[string]string {
+	env := make(map[string]string, len(r.extraEnv)+8)
+	for k, v := range r.extraEnv {
+		env[k] = v
+	}
+
+	env["IGLOO_USER"] = r.username
+	env["IGLOO_UID"] = strconv.Itoa(os.Getuid())
+	env["IGLOO_PROJECT"] = r.projectName
+	env["IGLOO_WORKSPACE"] = r.WorkspacePath()
+	env["IGLOO_DISTRO"] = r.distro
+	env["IGLOO_RELEASE"] = r.release
+	env["IGLOO_CONTAINER"] = r.instance
+	env["IGLOO_PHASE"] = r.phase
+
+	return env
+}
+
+// Script is a single init script, either from the project or a shared library
+type Script struct {
+	Name          string // File name, used for ordering
+	HostPath      string // Path on the host
+	ContainerPath string // Path inside the container
+	Library       bool   // True if included from a shared library
+}
+
+// Scripts returns every script that would be run, in order. Project scripts
+// and included library scripts are merged and sorted by name; a project
+// script shadows a library script with the same name. In the start phase
+// only the project's on-start hooks are returned.
+func (r *Runner) Scripts() ([]Script, error) {
+	scriptsPath := config.ScriptsPath()
+	if r.phase == PhaseStart {
+		scriptsPath = config.StartHooksPath()
 	}
+	hostScriptsDir := filepath.Join(r.projectDir, scriptsPath)
+	containerScriptsDir := filepath.Join(r.WorkspacePath(), scriptsPath)
 
-	// Filter and sort script files
-	var scripts []string
+	entries, err := os.ReadDir(hostScriptsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	byName := make(map[string]Script)
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
 		name := entry.Name()
-		// Skip hidden files and non-executable looking files
-		if len(name) > 0 && name[0] != '.' {
-			scripts = append(scripts, name)
+		// Skip hidden files
+		if len(name) == 0 || name[0] == '.' {
+			continue
+		}
+		byName[name] = Script{
+			Name:          name,
+			HostPath:      filepath.Join(hostScriptsDir, name),
+			ContainerPath: filepath.Join(containerScriptsDir, name),
+		}
+	}
+
+	if len(r.options.Include) > 0 && r.phase != PhaseStart {
+		included, err := config.ResolveIncludes(r.options.Include, r.options.Library)
+		if err != nil {
+			return nil, err
+		}
+		for _, lib := range included {
+			if _, exists := byName[lib.Name]; exists {
+				continue
+			}
+			byName[lib.Name] = Script{
+				Name:          lib.Name,
+				HostPath:      lib.Path,
+				ContainerPath: filepath.Join(LibraryDir, lib.Name),
+				Library:       true,
+			}
+		}
+	}
+
+	scripts := make([]Script, 0, len(byName))
+	for _, s := range byName {
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Name < scripts[j].Name
+	})
+
+	return scripts, nil
+}
+
+// Result records the outcome of running a single script
+type Result struct {
+	Script   string
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// RunScripts executes all scripts in the .igloo/scripts directory in lexicographical order.
+// It returns a result for every script that was attempted. Unless keep-going is enabled,
+// execution stops at the first failing script.
+func (r *Runner) RunScripts(ctx context.Context) ([]Result, error) {
+	scripts, err := r.Scripts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scripts: %w", err)
+	}
+	scripts = scriptsFrom(scripts, r.startAt)
+	if len(r.only) > 0 {
+		if scripts, err = onlyScripts(scripts, r.only); err != nil {
+			return nil, err
 		}
 	}
 
 	if len(scripts) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// Sort lexicographically
-	sort.Strings(scripts)
+	env := r.Env()
 
-	// The project directory is mounted at /home/$USER/workspace/$projectName
-	workspacePath := fmt.Sprintf("/home/%s/workspace/%s", r.username, r.projectName)
-	containerScriptsDir := filepath.Join(workspacePath, config.ScriptsPath())
+	defaults := Settings{
+		Timeout: r.options.Timeout,
+		Retries: r.options.Retries,
+	}
 
 	// Execute each script in order
-	for _, scriptName := range scripts {
-		fullScriptPath := filepath.Join(containerScriptsDir, scriptName)
+	var results []Result
+	failed := 0
+	for _, s := range scripts {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
 
-		// Make the script executable
-		if err := r.client.ExecAsRoot(r.instance, "chmod", "+x", fullScriptPath); err != nil {
-			return fmt.Errorf("failed to make script %s executable: %w", scriptName, err)
+		settings, err := ParseHeaderFile(s.HostPath, defaults)
+		if err != nil {
+			return results, fmt.Errorf("failed to read script %s: %w", s.Name, err)
+		}
+
+		if r.progress != nil {
+			r.progress.ScriptStarted(s)
+		}
+		result := r.runScript(ctx, s, settings, env)
+		results = append(results, result)
+		if r.progress != nil {
+			r.progress.ScriptFinished(result)
 		}
 
-		// Execute the script as root
-		if err := r.client.ExecAsRoot(r.instance, "/bin/sh", "-c", fullScriptPath); err != nil {
-			return fmt.Errorf("script %s failed: %w", scriptName, err)
+		if result.Err != nil {
+			failed++
+			if !r.options.KeepGoing || ctx.Err() != nil {
+				return results, fmt.Errorf("script %s failed: %w", s.Name, result.Err)
+			}
 		}
 	}
 
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d script(s) failed", failed, len(results))
+	}
+
+	return results, nil
+}
+
+// scriptsFrom returns the scripts that sort at or after name
+func scriptsFrom(scripts []Script, name string) []Script {
+	for i, s := range scripts {
+		if s.Name >= name {
+			return scripts[i:]
+		}
+	}
 	return nil
 }
 
-// GetScripts returns the list of scripts that would be run, in order
-func (r *Runner) GetScripts() ([]string, error) {
-	scriptsPath := filepath.Join(r.projectDir, config.ScriptsPath())
+// onlyScripts returns the scripts matching names, in run order, failing if
+// any name matches nothing
+func onlyScripts(scripts []Script, names []string) ([]Script, error) {
+	selected := make([]bool, len(scripts))
+	for _, name := range names {
+		i := slices.IndexFunc(scripts, func(s Script) bool {
+			return s.Name == name || s.Name == name+".sh"
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("no script named %s", name)
+		}
+		selected[i] = true
+	}
 
-	entries, err := os.ReadDir(scriptsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+	var only []Script
+	for i, s := range scripts {
+		if selected[i] {
+			only = append(only, s)
 		}
-		return nil, err
 	}
+	return only, nil
+}
 
-	var scripts []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+// runScript executes a single script, retrying on failure as configured
+func (r *Runner) runScript(ctx context.Context, s Script, settings Settings, env map[string]string) Result {
+	result := Result{Script: s.Name}
+	start := time.Now()
+
+	if r.local {
+		// Library scripts were copied in when the container was provisioned
+		if err := os.Chmod(s.ContainerPath, 0755); err != nil && !s.Library {
+			result.Err = fmt.Errorf("failed to make script executable: %w", err)
+			result.Duration = time.Since(start)
+			return result
 		}
-		name := entry.Name()
-		if len(name) > 0 && name[0] != '.' {
-			scripts = append(scripts, name)
+	} else if s.Library {
+		// Library scripts aren't mounted, so copy them in as executables
+		if err := r.client.PushFile(ctx, r.instance, s.HostPath, s.ContainerPath, 0755); err != nil {
+			result.Err = fmt.Errorf("failed to copy library script: %w", err)
+			result.Duration = time.Since(start)
+			return result
 		}
+	} else if err := r.client.ExecAsRootWithEnv(ctx, r.instance, nil, "chmod", "+x", s.ContainerPath); err != nil {
+		// Make the script executable
+		result.Err = fmt.Errorf("failed to make script executable: %w", err)
+		result.Duration = time.Since(start)
+		return result
 	}
 
-	sort.Strings(scripts)
-	return scripts, nil
+	for attempt := 1; attempt <= settings.Retries+1; attempt++ {
+		result.Attempts = attempt
+		result.Err = r.execScript(ctx, s.ContainerPath, settings.Timeout, env)
+		if result.Err == nil || ctx.Err() != nil || attempt > settings.Retries {
+			break
+		}
+
+		// Back off briefly before retrying, unless we're interrupted
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(attempt) * retryDelay):
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// execScript runs a script as root, enforcing the timeout if one is set
+func (r *Runner) execScript(ctx context.Context, fullScriptPath string, timeout time.Duration, env map[string]string) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var err error
+	if r.local {
+		args := localArgs(os.Geteuid(), env, fullScriptPath)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = os.Environ()
+		cmd.Stdout = os.Stdout
+		if r.output != nil {
+			cmd.Stdout = r.output
+		}
+		cmd.Stderr = os.Stderr
+		// Killing sudo would leave the root script running, so ask it to
+		// stop instead. sudo only relays signals from outside the command's
+		// process group, hence the group of its own.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGTERM)
+		}
+		cmd.WaitDelay = 10 * time.Second
+		err = cmd.Run()
+	} else {
+		err = r.client.ExecAsRootWithEnv(ctx, r.instance, env, scriptArgs(fullScriptPath)...)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// localArgs returns the command that runs a script as root with env set,
+// going through sudo unless igloo already runs as root
+func localArgs(euid int, env map[string]string, fullScriptPath string) []string {
+	var args []string
+	if euid != 0 {
+		// Never prompt: the script runs in its own process group, away from
+		// the terminal
+		args = append(args, "sudo", "-n")
+	}
+	args = append(args, "env")
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, k+"="+env[k])
+	}
+	return append(args, scriptArgs(fullScriptPath)...)
+}
+
+// scriptArgs returns the command that runs the script at path. The path is
+// passed as an argument, since a mirrored project path may contain spaces,
+// and exec'd so the script's own #! line picks the interpreter.
+func scriptArgs(path string) []string {
+	return []string{"/bin/sh", "-c", `exec "$0"`, path}
+}
+
+// GetScripts returns the names of the scripts that would be run, in order
+func (r *Runner) GetScripts() ([]string, error) {
+	scripts, err := r.Scripts()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, s := range scripts {
+		names = append(names, s.Name)
+	}
+	return names, nil
 }
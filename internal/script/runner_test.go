@@ -3,6 +3,8 @@ package script
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/frostyard/igloo/internal/config"
@@ -205,3 +207,304 @@ func TestRunnerFields(t *testing.T) {
 		t.Errorf("projectDir = %q, want %q", runner.projectDir, "/home/dev/projects/myapp")
 	}
 }
+
+func TestRunnerEnv(t *testing.T) {
+	runner := NewRunner(nil, "igloo-myapp", "developer", "myapp", "/home/dev/projects/myapp")
+	runner.SetImage("images:debian/trixie/cloud")
+
+	env := runner.Env()
+
+	expected := map[string]string{
+		"IGLOO_USER":      "developer",
+		"IGLOO_UID":       strconv.Itoa(os.Getuid()),
+		"IGLOO_PROJECT":   "myapp",
+		"IGLOO_WORKSPACE": "/home/developer/workspace/myapp",
+		"IGLOO_DISTRO":    "debian",
+		"IGLOO_RELEASE":   "trixie",
+		"IGLOO_CONTAINER": "igloo-myapp",
+		"IGLOO_PHASE":     PhaseProvision,
+	}
+	for k, v := range expected {
+		if env[k] != v {
+			t.Errorf("Env()[%q] = %q, want %q", k, env[k], v)
+		}
+	}
+}
+
+func TestRunnerEnv_UserVariables(t *testing.T) {
+	runner := NewRunner(nil, "igloo-myapp", "developer", "myapp", "/tmp/myapp")
+	runner.SetEnv(map[string]string{
+		"GOPROXY":    "direct",
+		"IGLOO_USER": "override-attempt",
+	})
+
+	env := runner.Env()
+
+	if env["GOPROXY"] != "direct" {
+		t.Errorf("Env()[GOPROXY] = %q, want %q", env["GOPROXY"], "direct")
+	}
+	// Standard variables must not be overridden by user-defined ones
+	if env["IGLOO_USER"] != "developer" {
+		t.Errorf("Env()[IGLOO_USER] = %q, want %q", env["IGLOO_USER"], "developer")
+	}
+}
+
+func TestRunnerSetPhase(t *testing.T) {
+	runner := NewRunner(nil, "test", "user", "proj", "/tmp/test")
+	runner.SetPhase("start")
+
+	if got := runner.Env()["IGLOO_PHASE"]; got != "start" {
+		t.Errorf("Env()[IGLOO_PHASE] = %q, want %q", got, "start")
+	}
+}
+
+func TestScripts_MergesLibrary(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+
+	scriptsDir := filepath.Join(tmpDir, config.ScriptsPath())
+	libDir := config.UserScriptsPath()
+	for _, dir := range []string{scriptsDir, libDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []string{
+		filepath.Join(scriptsDir, "01-packages.sh"),
+		filepath.Join(scriptsDir, "docker.sh"), // shadows the library copy
+		filepath.Join(libDir, "00-vscode.sh"),
+		filepath.Join(libDir, "docker.sh"),
+	}
+	for _, path := range files {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRunner(nil, "test", "dev", "proj", tmpDir)
+	runner.SetOptions(config.ScriptsConfig{Include: []string{"00-vscode", "docker"}})
+
+	scripts, err := runner.Scripts()
+	if err != nil {
+		t.Fatalf("Scripts() error = %v", err)
+	}
+
+	want := []Script{
+		{
+			Name:          "00-vscode.sh",
+			HostPath:      filepath.Join(libDir, "00-vscode.sh"),
+			ContainerPath: filepath.Join(LibraryDir, "00-vscode.sh"),
+			Library:       true,
+		},
+		{
+			Name:          "01-packages.sh",
+			HostPath:      filepath.Join(scriptsDir, "01-packages.sh"),
+			ContainerPath: "/home/dev/workspace/proj/.igloo/scripts/01-packages.sh",
+		},
+		{
+			Name:          "docker.sh",
+			HostPath:      filepath.Join(scriptsDir, "docker.sh"),
+			ContainerPath: "/home/dev/workspace/proj/.igloo/scripts/docker.sh",
+		},
+	}
+	if len(scripts) != len(want) {
+		t.Fatalf("Scripts() = %+v, want %+v", scripts, want)
+	}
+	for i := range want {
+		if scripts[i] != want[i] {
+			t.Errorf("Scripts()[%d] = %+v, want %+v", i, scripts[i], want[i])
+		}
+	}
+}
+
+func TestScripts_MissingInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+
+	runner := NewRunner(nil, "test", "dev", "proj", tmpDir)
+	runner.SetOptions(config.ScriptsConfig{Include: []string{"missing"}})
+
+	if _, err := runner.Scripts(); err == nil {
+		t.Error("Scripts() should fail when an included script is missing")
+	}
+}
+
+func TestRunnerSetWorkspacePath(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptsDir := filepath.Join(tmpDir, config.ScriptsPath())
+	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(scriptsDir, "01-init.sh"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := NewRunner(nil, "test", "dev", "proj", tmpDir)
+	runner.SetWorkspacePath(tmpDir)
+
+	if got := runner.Env()["IGLOO_WORKSPACE"]; got != tmpDir {
+		t.Errorf("Env()[IGLOO_WORKSPACE] = %q, want %q", got, tmpDir)
+	}
+
+	scripts, err := runner.Scripts()
+	if err != nil {
+		t.Fatalf("Scripts() error = %v", err)
+	}
+	want := filepath.Join(tmpDir, config.ScriptsPath(), "01-init.sh")
+	if len(scripts) != 1 || scripts[0].ContainerPath != want {
+		t.Errorf("Scripts() = %+v, want container path %q", scripts, want)
+	}
+}
+
+func TestScriptsFrom(t *testing.T) {
+	scripts := []Script{{Name: "01-base.sh"}, {Name: "02-tools.sh"}, {Name: "03-config.sh"}}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"", []string{"01-base.sh", "02-tools.sh", "03-config.sh"}},
+		{"02-tools.sh", []string{"02-tools.sh", "03-config.sh"}},
+		{"02-removed.sh", []string{"02-tools.sh", "03-config.sh"}},
+		{"99-last.sh", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range scriptsFrom(scripts, tt.name) {
+				got = append(got, s.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("scriptsFrom(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("scriptsFrom(%q)[%d] = %q, want %q", tt.name, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScripts_StartPhase(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+
+	hooksDir := filepath.Join(tmpDir, config.StartHooksPath())
+	libDir := config.UserScriptsPath()
+	for _, dir := range []string{hooksDir, libDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := []string{
+		filepath.Join(tmpDir, config.ScriptsPath(), "01-packages.sh"),
+		filepath.Join(hooksDir, "10-services.sh"),
+		filepath.Join(libDir, "docker.sh"),
+	}
+	for _, path := range files {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRunner(nil, "test", "dev", "proj", tmpDir)
+	runner.SetOptions(config.ScriptsConfig{Include: []string{"docker"}})
+
+	// The init scripts don't include the hooks directory
+	names, err := runner.GetScripts()
+	if err != nil {
+		t.Fatalf("GetScripts() error = %v", err)
+	}
+	if len(names) != 2 || names[0] != "01-packages.sh" || names[1] != "docker.sh" {
+		t.Errorf("provision GetScripts() = %v, want [01-packages.sh docker.sh]", names)
+	}
+
+	// The start phase runs only the project's hooks
+	runner.SetPhase(PhaseStart)
+	scripts, err := runner.Scripts()
+	if err != nil {
+		t.Fatalf("Scripts() error = %v", err)
+	}
+	want := Script{
+		Name:          "10-services.sh",
+		HostPath:      filepath.Join(hooksDir, "10-services.sh"),
+		ContainerPath: "/home/dev/workspace/proj/.igloo/scripts/on-start/10-services.sh",
+	}
+	if len(scripts) != 1 || scripts[0] != want {
+		t.Errorf("start Scripts() = %+v, want [%+v]", scripts, want)
+	}
+}
+
+func TestOnlyScripts(t *testing.T) {
+	scripts := []Script{{Name: "01-base.sh"}, {Name: "02-tools.sh"}, {Name: "03-config"}}
+
+	got, err := onlyScripts(scripts, []string{"03-config", "01-base"})
+	if err != nil {
+		t.Fatalf("onlyScripts() error: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "01-base.sh" || got[1].Name != "03-config" {
+		t.Errorf("onlyScripts() = %v, want 01-base.sh and 03-config in run order", got)
+	}
+
+	if _, err := onlyScripts(scripts, []string{"02-tools.sh", "04-missing"}); err == nil {
+		t.Error("onlyScripts() with an unknown name should fail")
+	}
+}
+
+func TestLocalArgs(t *testing.T) {
+	env := map[string]string{"IGLOO_PHASE": "provision", "IGLOO_USER": "dev"}
+
+	want := []string{"env", "IGLOO_PHASE=provision", "IGLOO_USER=dev", "/bin/sh", "-c", `exec "$0"`, "/w/01.sh"}
+	if got := localArgs(0, env, "/w/01.sh"); !reflect.DeepEqual(got, want) {
+		t.Errorf("localArgs() as root = %v, want %v", got, want)
+	}
+	if got := localArgs(1000, env, "/w/01.sh"); !reflect.DeepEqual(got, append([]string{"sudo", "-n"}, want...)) {
+		t.Errorf("localArgs() as a user = %v, want it to go through sudo", got)
+	}
+}
+
+func TestRunScripts_Local(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("running scripts locally as a user needs sudo")
+	}
+
+	// A mirrored project path, with a space in it
+	tmpDir := filepath.Join(t.TempDir(), "My Projects", "api")
+	scriptsDir := filepath.Join(tmpDir, config.ScriptsPath())
+	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(tmpDir, "out")
+	scripts := map[string]string{
+		"01-first.sh":  "#!/bin/sh\necho \"first $IGLOO_PHASE\" >> \"$IGLOO_WORKSPACE/out\"\n",
+		"02-second.sh": "#!/bin/sh\necho second >> \"$IGLOO_WORKSPACE/out\"\n",
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(scriptsDir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewRunner(nil, "test", "dev", "proj", tmpDir)
+	runner.SetWorkspacePath(tmpDir)
+	runner.SetLocal(true)
+	runner.SetOnly([]string{"01-first"})
+
+	results, err := runner.RunScripts(t.Context())
+	if err != nil {
+		t.Fatalf("RunScripts() error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first provision\n" {
+		t.Errorf("scripts wrote %q, want only the first script's output", data)
+	}
+}
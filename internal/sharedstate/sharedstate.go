@@ -0,0 +1,75 @@
+// Package sharedstate links directories that every igloo shares, such as
+// editor settings, into a container's home directory. The directories live
+// on the host under config.SharedStateDir, which is mounted into each
+// container that uses it.
+package sharedstate
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// Device is the name of the disk device that mounts the shared state
+const Device = "shared-state"
+
+// ContainerDir is where the shared state is mounted in the container
+const ContainerDir = "/var/lib/igloo/shared_state"
+
+// ErrExists is returned when a path in the container's home is already a
+// real file or directory, which linking would hide
+var ErrExists = errors.New("already exists in the container and is not a shared state link")
+
+// exitExists is the exit status the link script uses for ErrExists
+const exitExists = 3
+
+// linkScript points $1 at $2, replacing an earlier link but never a real
+// file or directory
+const linkScript = `mkdir -p "$(dirname "$1")" || exit 1
+if [ -L "$1" ] || [ ! -e "$1" ]; then exec ln -sfn "$2" "$1"; fi
+exit 3`
+
+// unlinkScript removes $1 if it is a link to $2
+const unlinkScript = `if [ -L "$1" ] && [ "$(readlink "$1")" = "$2" ]; then exec rm "$1"; fi`
+
+// Configure mounts the host's shared state into the container, unless it
+// already is
+func Configure(client *incus.Client, name string) error {
+	if exists, err := client.DeviceExists(name, Device); err == nil && exists {
+		return nil
+	}
+	return client.AddDiskDevice(name, Device, config.SharedStateDir(), ContainerDir)
+}
+
+// Remove unmounts the shared state from a container that no longer links
+// any of it
+func Remove(client *incus.Client, name string) error {
+	if exists, err := client.DeviceExists(name, Device); err != nil || !exists {
+		return err
+	}
+	return client.RemoveDevice(name, Device)
+}
+
+// Link links a shared path into the user's home directory in the container.
+// Its host directory must already exist; see config.EnsureSharedState.
+func Link(client *incus.Client, name, username, path string) error {
+	target := filepath.Join("/home", username, path)
+	_, err := client.ExecAsUserOutput(name, username, "/bin/sh", "-c", linkScript, "sh", target, filepath.Join(ContainerDir, path))
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitExists {
+		return fmt.Errorf("~/%s %w", path, ErrExists)
+	}
+	return err
+}
+
+// Unlink removes a shared path's link from the container, leaving the
+// shared directory on the host for the other igloos
+func Unlink(client *incus.Client, name, username, path string) error {
+	target := filepath.Join("/home", username, path)
+	_, err := client.ExecAsUserOutput(name, username, "/bin/sh", "-c", unlinkScript, "sh", target, filepath.Join(ContainerDir, path))
+	return err
+}
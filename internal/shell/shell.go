@@ -0,0 +1,108 @@
+// Package shell marks interactive shells in an igloo container, so they
+// can't be mistaken for the host: a profile.d snippet prefixes the prompt
+// with the igloo's name and puts it in the terminal title.
+//
+// The snippet reads its settings from environment variables that igloo sets
+// on every 'igloo enter' and 'igloo exec', so changes to [shell] apply
+// without reprovisioning.
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/guest"
+	"github.com/frostyard/igloo/internal/incus"
+)
+
+// ProfilePath is where the snippet is installed in the container. Login
+// shells source it through /etc/profile; a ~/.bashrc that sets its own
+// prompt can source it again at the end.
+const ProfilePath = "/etc/profile.d/igloo.sh"
+
+// Environment variables read by the snippet
+const (
+	EnvName   = "IGLOO_NAME"   // Name shown in the prompt and title
+	EnvPrompt = "IGLOO_PROMPT" // "0" leaves the prompt alone
+	EnvTitle  = "IGLOO_TITLE"  // "0" leaves the terminal title alone
+)
+
+// Env returns the environment for a shell or command in the container
+func Env(cfg config.ShellConfig, container, projectDir string) map[string]string {
+	name := cfg.Label
+	if name == "" {
+		name = container
+	}
+	return map[string]string{
+		EnvName:          name,
+		guest.EnvProject: filepath.Base(projectDir),
+		EnvPrompt:        flag(!cfg.HidePrompt),
+		EnvTitle:         flag(!cfg.HideTitle),
+	}
+}
+
+func flag(on bool) string {
+	if on {
+		return "1"
+	}
+	return "0"
+}
+
+// profile adds the name to the front of PS1 and an OSC 0 title sequence to
+// its end, after any title the user's own prompt sets. PROMPT_COMMAND puts
+// them back whenever something replaces PS1, like a ~/.bashrc read after
+// /etc/profile.
+const profile = `# Installed by igloo: shows which igloo a shell is in. If your ~/.bashrc
+# replaces PROMPT_COMMAND, source this file again at its end.
+if [ -n "${BASH_VERSION:-}" ] && [ -n "${PS1:-}" ] && [ -n "${IGLOO_NAME:-}" ]; then
+	__igloo_ps1_prefix=
+	__igloo_ps1_suffix=
+	if [ "${IGLOO_PROMPT:-1}" != 0 ]; then
+		__igloo_ps1_prefix="\[\e[1;36m\][${IGLOO_NAME}]\[\e[0m\] "
+	fi
+	if [ "${IGLOO_TITLE:-1}" != 0 ]; then
+		__igloo_ps1_suffix="\[\e]0;${IGLOO_NAME}: \w\a\]"
+	fi
+
+	__igloo_prompt() {
+		case "$PS1" in
+		"$__igloo_ps1_prefix"*) ;;
+		*) PS1="$__igloo_ps1_prefix$PS1" ;;
+		esac
+		case "$PS1" in
+		*"$__igloo_ps1_suffix") ;;
+		*) PS1="$PS1$__igloo_ps1_suffix" ;;
+		esac
+	}
+
+	case ";${PROMPT_COMMAND:-};" in
+	*";__igloo_prompt;"*) ;;
+	*) PROMPT_COMMAND="__igloo_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+	esac
+	__igloo_prompt
+fi
+`
+
+// Install writes the prompt snippet into the container
+func Install(ctx context.Context, client *incus.Client, name string) error {
+	f, err := os.CreateTemp("", "igloo-profile-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.WriteString(profile); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := client.PushFile(ctx, name, f.Name(), ProfilePath, 0644); err != nil {
+		return fmt.Errorf("failed to install %s: %w", ProfilePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frostyard/igloo/internal/config"
+	"github.com/frostyard/igloo/internal/guest"
+)
+
+func TestEnv(t *testing.T) {
+	env := Env(config.ShellConfig{}, "igloo-api", "/home/dev/work/api")
+	want := map[string]string{EnvName: "igloo-api", guest.EnvProject: "api", EnvPrompt: "1", EnvTitle: "1"}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("Env()[%s] = %q, want %q", k, env[k], v)
+		}
+	}
+
+	env = Env(config.ShellConfig{HideTitle: true, Label: "api"}, "igloo-api", "/home/dev/work/api")
+	if env[EnvName] != "api" || env[EnvTitle] != "0" || env[EnvPrompt] != "1" {
+		t.Errorf("Env() with label and no title = %v", env)
+	}
+}
+
+// prompt sources the snippet in bash with env set, then runs script and
+// returns its output
+func prompt(t *testing.T, env []string, script string) string {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	path := filepath.Join(t.TempDir(), "igloo.sh")
+	if err := os.WriteFile(path, []byte(profile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("bash", "--norc", "--noprofile", "-c", `PS1='\u@\h:\w\$ '; . "$1"; `+script, "bash", path)
+	cmd.Env = append([]string{"PATH=" + os.Getenv("PATH")}, env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestProfile(t *testing.T) {
+	env := []string{"IGLOO_NAME=igloo-api", "IGLOO_PROMPT=1", "IGLOO_TITLE=1"}
+	want := `\[\e[1;36m\][igloo-api]\[\e[0m\] \u@\h:\w\$ \[\e]0;igloo-api: \w\a\]`
+
+	if got := prompt(t, env, `echo "$PS1"`); got != want {
+		t.Errorf("PS1 = %q, want %q", got, want)
+	}
+
+	// Sourcing again, as a ~/.bashrc hook does, changes nothing
+	if got := prompt(t, env, `. "$1"; . "$1"; echo "$PS1|$PROMPT_COMMAND"`); got != want+"|__igloo_prompt" {
+		t.Errorf("PS1|PROMPT_COMMAND after sourcing again = %q", got)
+	}
+
+	// A prompt replaced later gets the name back at the next prompt
+	got := prompt(t, env, `PS1='> '; __igloo_prompt; echo "$PS1"`)
+	if want := `\[\e[1;36m\][igloo-api]\[\e[0m\] > \[\e]0;igloo-api: \w\a\]`; got != want {
+		t.Errorf("PS1 after replacing = %q, want %q", got, want)
+	}
+}
+
+func TestProfile_Off(t *testing.T) {
+	if got := prompt(t, []string{"IGLOO_NAME=igloo-api", "IGLOO_PROMPT=0", "IGLOO_TITLE=0"}, `echo "$PS1"`); got != `\u@\h:\w\$` {
+		t.Errorf("PS1 with prompt and title off = %q", got)
+	}
+	// Outside an igloo the snippet does nothing
+	if got := prompt(t, nil, `echo "$PS1|${PROMPT_COMMAND:-}"`); got != `\u@\h:\w\$ |` {
+		t.Errorf("PS1 outside an igloo = %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNonInteractive is returned when a confirmation is needed but there is no
+// terminal to ask on and no --yes or --no answer was given
+var ErrNonInteractive = errors.New("confirmation required but running non-interactively (use --yes or --no)")
+
+// Answer is a preset response to confirmation prompts
+type Answer int
+
+const (
+	AnswerAsk Answer = iota // Ask the user
+	AnswerYes               // Assume yes without asking
+	AnswerNo                // Assume no without asking
+)
+
+// Prompter asks the user to confirm actions, honoring preset answers and
+// refusing to block when there is nobody to answer
+type Prompter struct {
+	in          *bufio.Reader
+	out         io.Writer
+	answer      Answer
+	interactive bool
+}
+
+// NewPrompter creates a Prompter reading from stdin and writing to stderr.
+// Prompts are only shown if stdin is a terminal and nonInteractive is false.
+func NewPrompter(answer Answer, nonInteractive bool) *Prompter {
+	return &Prompter{
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stderr,
+		answer:      answer,
+		interactive: !nonInteractive && IsTerminal(os.Stdin),
+	}
+}
+
+// newPrompter creates a Prompter with explicit input and output, for tests
+func newPrompter(in io.Reader, out io.Writer, answer Answer, interactive bool) *Prompter {
+	return &Prompter{
+		in:          bufio.NewReader(in),
+		out:         out,
+		answer:      answer,
+		interactive: interactive,
+	}
+}
+
+// Confirm asks a yes/no question, defaulting to no. Preset answers are used
+// without reading input; otherwise ErrNonInteractive is returned when there
+// is no terminal to ask on.
+func (p *Prompter) Confirm(question string) (bool, error) {
+	styles := NewStyles()
+	prompt := styles.Warning(question + " [y/N]: ")
+
+	switch p.answer {
+	case AnswerYes:
+		_, _ = fmt.Fprintln(p.out, prompt+"yes (--yes)")
+		return true, nil
+	case AnswerNo:
+		_, _ = fmt.Fprintln(p.out, prompt+"no (--no)")
+		return false, nil
+	}
+
+	if !p.interactive {
+		return false, fmt.Errorf("%w: %s", ErrNonInteractive, question)
+	}
+
+	_, _ = fmt.Fprint(p.out, prompt)
+	response, err := p.in.ReadString('\n')
+	if err != nil && response == "" {
+		// EOF without an answer counts as no
+		_, _ = fmt.Fprintln(p.out)
+		return false, nil
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes", nil
+}
+
+// IsTerminal reports whether f is connected to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
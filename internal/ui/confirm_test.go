@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		answer      Answer
+		interactive bool
+		want        bool
+		wantErr     error
+	}{
+		{"yes", "y\n", AnswerAsk, true, true, nil},
+		{"full yes", "YES\n", AnswerAsk, true, true, nil},
+		{"no", "n\n", AnswerAsk, true, false, nil},
+		{"empty defaults to no", "\n", AnswerAsk, true, false, nil},
+		{"eof defaults to no", "", AnswerAsk, true, false, nil},
+		{"preset yes", "", AnswerYes, false, true, nil},
+		{"preset no ignores input", "y\n", AnswerNo, true, false, nil},
+		{"non-interactive", "y\n", AnswerAsk, false, false, ErrNonInteractive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := newPrompter(strings.NewReader(tt.input), &out, tt.answer, tt.interactive)
+
+			got, err := p.Confirm("Remove it?")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Confirm() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Confirm() = %v, want %v", got, tt.want)
+			}
+			if err == nil && !strings.Contains(out.String(), "Remove it?") {
+				t.Errorf("Confirm() output %q does not include the question", out.String())
+			}
+		})
+	}
+}
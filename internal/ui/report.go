@@ -0,0 +1,233 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format for command results and progress
+type Format string
+
+const (
+	FormatText Format = "text" // Styled text for humans
+	FormatJSON Format = "json" // JSON results, JSON-lines progress events
+	FormatYAML Format = "yaml" // YAML documents
+)
+
+// ParseFormat validates an --output value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatYAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported output format %q (want text, json or yaml)", s)
+}
+
+// Event types emitted by a Reporter
+const (
+	EventInfo      = "info"
+	EventSuccess   = "success"
+	EventWarning   = "warning"
+	EventStepStart = "step_start"
+	EventStepDone  = "step_done"
+)
+
+// Event is a single progress event in structured output
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Phase      string    `json:"phase,omitempty"`
+	Step       string    `json:"step,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	DurationMS int64     `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Reporter is the single place commands send progress messages and results.
+// In text mode it prints styled lines; in JSON mode every message is a JSON
+// line and results are JSON documents; in YAML mode both are YAML documents.
+type Reporter struct {
+	format Format
+	out    io.Writer
+	styles *Styles
+	mu     sync.Mutex
+}
+
+// NewReporter creates a Reporter writing in format to out
+func NewReporter(format Format, out io.Writer) *Reporter {
+	return &Reporter{
+		format: format,
+		out:    out,
+		styles: NewStyles(),
+	}
+}
+
+// Format returns the output format
+func (r *Reporter) Format() Format {
+	return r.format
+}
+
+// Structured reports whether output is meant for machines rather than humans
+func (r *Reporter) Structured() bool {
+	return r.format != FormatText
+}
+
+// Info reports an informational message
+func (r *Reporter) Info(msg string) {
+	r.message(EventInfo, msg, r.styles.Info)
+}
+
+// Success reports that something completed successfully
+func (r *Reporter) Success(msg string) {
+	r.message(EventSuccess, msg, r.styles.Success)
+}
+
+// Warning reports a problem that doesn't stop the command
+func (r *Reporter) Warning(msg string) {
+	r.message(EventWarning, msg, r.styles.Warning)
+}
+
+// Detail reports an informational message followed by a verbatim block of
+// text, such as a rendered config file
+func (r *Reporter) Detail(msg, detail string) {
+	if !r.Structured() {
+		r.println(r.styles.Info(msg))
+		r.print(detail)
+		if !strings.HasSuffix(detail, "\n") {
+			r.print("\n")
+		}
+		return
+	}
+	r.emit(Event{Type: EventInfo, Message: msg, Detail: detail})
+}
+
+// message emits a plain message event, or a styled line in text mode
+func (r *Reporter) message(eventType, msg string, style func(string) string) {
+	if !r.Structured() {
+		r.println(style(msg))
+		return
+	}
+	r.emit(Event{Type: eventType, Message: msg})
+}
+
+// Step is a timed unit of work within a phase
+type Step struct {
+	reporter *Reporter
+	phase    string
+	name     string
+	start    time.Time
+}
+
+// StartStep reports the start of a step. In text mode msg is printed as an
+// info line; call Done on the result when the step finishes.
+func (r *Reporter) StartStep(phase, step, msg string) *Step {
+	if r.Structured() {
+		r.emit(Event{Type: EventStepStart, Phase: phase, Step: step, Message: msg})
+	} else {
+		r.println(r.styles.Info(msg))
+	}
+	return &Step{reporter: r, phase: phase, name: step, start: time.Now()}
+}
+
+// Done reports the end of a step with its duration and error, if any.
+// Text mode leaves reporting the outcome to the caller.
+func (s *Step) Done(err error) {
+	if !s.reporter.Structured() {
+		return
+	}
+	event := Event{
+		Type:       EventStepDone,
+		Phase:      s.phase,
+		Step:       s.name,
+		DurationMS: time.Since(s.start).Milliseconds(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.reporter.emit(event)
+}
+
+// Result writes a command's result. In text mode text is called to render it
+// for humans; otherwise v is encoded in the structured format. A JSON result
+// is a single line, so it can follow progress events in the same stream.
+func (r *Reporter) Result(v any, text func() error) error {
+	switch r.format {
+	case FormatJSON:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		r.println(string(data))
+		return nil
+	case FormatYAML:
+		data, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		r.print("---\n" + string(data))
+		return nil
+	default:
+		return text()
+	}
+}
+
+// emit writes a structured event: one JSON line, or one YAML document
+func (r *Reporter) emit(event Event) {
+	event.Time = time.Now().UTC()
+
+	var data []byte
+	var err error
+	if r.format == FormatYAML {
+		data, err = toYAML(event)
+		data = append([]byte("---\n"), data...)
+	} else {
+		data, err = json.Marshal(event)
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return
+	}
+	r.print(string(data))
+}
+
+func (r *Reporter) println(s string) {
+	r.print(s + "\n")
+}
+
+func (r *Reporter) print(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = io.WriteString(r.out, s)
+}
+
+// toYAML encodes v as YAML using its JSON field names and order, so both
+// structured formats share one schema
+func toYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML; decode it into a node tree to keep key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetStyle(&node)
+	return yaml.Marshal(&node)
+}
+
+// resetStyle switches a node tree decoded from JSON to block style
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
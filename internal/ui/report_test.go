@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"text", "json", "yaml"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(\"xml\") should fail")
+	}
+}
+
+func TestReporterText(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(FormatText, &out)
+
+	r.Info("Starting container...")
+	r.StartStep("provision", "start", "Waiting for cloud-init...").Done(nil)
+
+	text := out.String()
+	if !strings.Contains(text, "Starting container...") || !strings.Contains(text, "Waiting for cloud-init...") {
+		t.Errorf("text output = %q, want both messages", text)
+	}
+	if strings.Contains(text, "{") {
+		t.Errorf("text output = %q, should not contain JSON", text)
+	}
+
+	called := false
+	if err := r.Result(map[string]string{"a": "b"}, func() error { called = true; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("Result() in text mode should call the text renderer")
+	}
+}
+
+func TestReporterJSONLines(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(FormatJSON, &out)
+
+	r.Warning("careful")
+	r.Detail("Rendered cloud-init:", "#cloud-config\n")
+	r.StartStep("provision", "scripts", "Running scripts").Done(errors.New("boom"))
+
+	var events []Event
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4: %+v", len(events), events)
+	}
+	if events[0].Type != EventWarning || events[0].Message != "careful" {
+		t.Errorf("events[0] = %+v, want warning", events[0])
+	}
+	if events[1].Detail != "#cloud-config\n" {
+		t.Errorf("events[1] = %+v, want cloud-init detail", events[1])
+	}
+	if events[2].Type != EventStepStart || events[2].Phase != "provision" || events[2].Step != "scripts" {
+		t.Errorf("events[2] = %+v, want step_start provision/scripts", events[2])
+	}
+	if events[3].Type != EventStepDone || events[3].Error != "boom" {
+		t.Errorf("events[3] = %+v, want step_done with error", events[3])
+	}
+	if events[3].Time.IsZero() {
+		t.Error("events should be timestamped")
+	}
+}
+
+func TestReporterResult(t *testing.T) {
+	result := struct {
+		Name    string   `json:"name"`
+		Version string   `json:"version"`
+		Scripts []string `json:"scripts"`
+	}{"igloo-api", "1", []string{"01-init.sh"}}
+
+	var out bytes.Buffer
+	if err := NewReporter(FormatJSON, &out).Result(result, nil); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON result %q: %v", out.String(), err)
+	}
+	if decoded["name"] != "igloo-api" {
+		t.Errorf("JSON result name = %v, want igloo-api", decoded["name"])
+	}
+	if n := strings.Count(out.String(), "\n"); n != 1 {
+		t.Errorf("JSON result spans %d lines, want 1: %q", n, out.String())
+	}
+
+	out.Reset()
+	if err := NewReporter(FormatYAML, &out).Result(result, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "---\nname: igloo-api\nversion: \"1\"\nscripts:\n    - 01-init.sh\n"
+	if out.String() != want {
+		t.Errorf("YAML result = %q, want %q", out.String(), want)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 
@@ -19,6 +21,16 @@ func makeVersionString() string {
 	return fmt.Sprintf("%s (Commit: %s) (Date: %s) (Built by: %s)", version, commit, date, builtBy)
 }
 
+// handleError prints command errors, except exit codes propagated from
+// commands run inside the container, which have already reported themselves
+func handleError(w io.Writer, styles fang.Styles, err error) {
+	var exitErr *cmd.ExitError
+	if errors.As(err, &exitErr) {
+		return
+	}
+	fang.DefaultErrorHandler(w, styles, err)
+}
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
@@ -26,7 +38,8 @@ func main() {
 	if err := fang.Execute(ctx, cmd.RootCmd(),
 		fang.WithVersion(makeVersionString()),
 		fang.WithNotifySignal(os.Interrupt),
+		fang.WithErrorHandler(handleError),
 	); err != nil {
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }